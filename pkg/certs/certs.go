@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+)
+
+// DefaultDuration is the default validity of the generated certificates
+const DefaultDuration = 24 * time.Hour
+
+// Bundle is a set of PEM-encoded certificates and keys for mutual TLS
+// authentication, all signed by the same self-signed CA.
+type Bundle struct {
+	CACert     []byte
+	ServerCert []byte
+	ServerKey  []byte
+	ClientCert []byte
+	ClientKey  []byte
+}
+
+// Generate generates a self-signed CA, and a server and a client
+// certificate signed by it. The server certificate is valid for the given
+// hosts, which can be either DNS names or IP addresses, and for
+// "localhost" and "127.0.0.1" if none is specified.
+func Generate(hosts ...string) (*Bundle, error) {
+	if len(hosts) == 0 {
+		hosts = []string{"localhost", "127.0.0.1"}
+	}
+	res := &Bundle{}
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ca := newTemplate("falcosecurity-testing-ca")
+	ca.IsCA = true
+	ca.BasicConstraintsValid = true
+	ca.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	caDer, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	res.CACert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDer})
+
+	server := newTemplate(hosts[0])
+	server.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			server.IPAddresses = append(server.IPAddresses, ip)
+		} else {
+			server.DNSNames = append(server.DNSNames, h)
+		}
+	}
+	res.ServerCert, res.ServerKey, err = sign(server, ca, caKey)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newTemplate("falcosecurity-testing-client")
+	client.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	res.ClientCert, res.ClientKey, err = sign(client, ca, caKey)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// CertPool returns a certificate pool containing the CA of the bundle
+func (b *Bundle) CertPool() *x509.CertPool {
+	res := x509.NewCertPool()
+	res.AppendCertsFromPEM(b.CACert)
+	return res
+}
+
+// ServerTLSConfig returns a TLS config for a server using the server
+// certificate of the bundle. If mtls is true, clients are required to
+// present a certificate signed by the CA of the bundle.
+func (b *Bundle) ServerTLSConfig(mtls bool) (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(b.ServerCert, b.ServerKey)
+	if err != nil {
+		return nil, err
+	}
+	res := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if mtls {
+		res.ClientCAs = b.CertPool()
+		res.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return res, nil
+}
+
+// ClientTLSConfig returns a TLS config for a client trusting the CA of
+// the bundle and presenting the client certificate of the bundle.
+func (b *Bundle) ClientTLSConfig(serverName string) (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(b.ClientCert, b.ClientKey)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		ServerName:   serverName,
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      b.CertPool(),
+	}, nil
+}
+
+func newTemplate(commonName string) *x509.Certificate {
+	serial, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(DefaultDuration),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+}
+
+// sign creates a new key and a certificate for it from the given
+// template, signed by the given CA, and returns both PEM-encoded
+func sign(template, ca *x509.Certificate, caKey *ecdsa.PrivateKey) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), nil
+}
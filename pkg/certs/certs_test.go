@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package certs
+
+import (
+	"crypto/tls"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+	b, err := Generate()
+	require.NoError(t, err)
+	serverConfig, err := b.ServerTLSConfig(true)
+	require.NoError(t, err)
+
+	// perform a mutual TLS handshake with the generated certificates
+	l, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write([]byte("ok"))
+			conn.Close()
+		}
+	}()
+	for _, serverName := range []string{"127.0.0.1", "localhost"} {
+		clientConfig, err := b.ClientTLSConfig(serverName)
+		require.NoError(t, err)
+		conn, err := tls.Dial("tcp", l.Addr().String(), clientConfig)
+		require.NoError(t, err, serverName)
+		data, err := io.ReadAll(conn)
+		conn.Close()
+		require.NoError(t, err, serverName)
+		assert.Equal(t, "ok", string(data), serverName)
+	}
+
+	// clients without a certificate must be rejected
+	conn, err := tls.Dial("tcp", l.Addr().String(), &tls.Config{ServerName: "localhost", RootCAs: b.CertPool()})
+	if err == nil {
+		_, err = io.ReadAll(conn)
+		conn.Close()
+	}
+	assert.Error(t, err)
+}
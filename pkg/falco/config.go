@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"bytes"
+
+	"github.com/falcosecurity/testing/pkg/run"
+	"gopkg.in/yaml.v3"
+)
+
+// Config represents a Falco configuration file (i.e. falco.yaml).
+// All fields are optional and are omitted from the serialized configuration
+// when not set, so that Falco uses their default values. Pointers are used
+// for scalar values for which the zero value is meaningful (e.g. false booleans).
+// Fields not modeled by this struct can be set through Extra.
+type Config struct {
+	RulesFile                 []string                    `yaml:"rules_file,omitempty"`
+	Engine                    *EngineConfig               `yaml:"engine,omitempty"`
+	Plugins                   []PluginConfig              `yaml:"plugins,omitempty"`
+	LoadPlugins               []string                    `yaml:"load_plugins,omitempty"`
+	WatchConfigFiles          *bool                       `yaml:"watch_config_files,omitempty"`
+	TimeFormatISO8601         *bool                       `yaml:"time_format_iso_8601,omitempty"`
+	Priority                  string                      `yaml:"priority,omitempty"`
+	JSONOutput                *bool                       `yaml:"json_output,omitempty"`
+	JSONIncludeOutputProperty *bool                       `yaml:"json_include_output_property,omitempty"`
+	JSONIncludeTagsProperty   *bool                       `yaml:"json_include_tags_property,omitempty"`
+	BufferedOutputs           *bool                       `yaml:"buffered_outputs,omitempty"`
+	RuleMatching              string                      `yaml:"rule_matching,omitempty"`
+	Outputs                   *OutputsConfig              `yaml:"outputs,omitempty"`
+	StdoutOutput              *OutputConfig               `yaml:"stdout_output,omitempty"`
+	SyslogOutput              *OutputConfig               `yaml:"syslog_output,omitempty"`
+	FileOutput                *FileOutputConfig           `yaml:"file_output,omitempty"`
+	HTTPOutput                *HTTPOutputConfig           `yaml:"http_output,omitempty"`
+	ProgramOutput             *ProgramOutputConfig        `yaml:"program_output,omitempty"`
+	GRPCOutput                *OutputConfig               `yaml:"grpc_output,omitempty"`
+	GRPC                      *GRPCConfig                 `yaml:"grpc,omitempty"`
+	Webserver                 *WebserverConfig            `yaml:"webserver,omitempty"`
+	LogStderr                 *bool                       `yaml:"log_stderr,omitempty"`
+	LogSyslog                 *bool                       `yaml:"log_syslog,omitempty"`
+	LogLevel                  string                      `yaml:"log_level,omitempty"`
+	LibsLogger                *LibsLoggerConfig           `yaml:"libs_logger,omitempty"`
+	OutputTimeout             *int                        `yaml:"output_timeout,omitempty"`
+	SyscallEventTimeouts      *SyscallEventTimeoutsConfig `yaml:"syscall_event_timeouts,omitempty"`
+	SyscallEventDrops         *SyscallEventDropsConfig    `yaml:"syscall_event_drops,omitempty"`
+	Metrics                   *MetricsConfig              `yaml:"metrics,omitempty"`
+	BaseSyscalls              *BaseSyscallsConfig         `yaml:"base_syscalls,omitempty"`
+	Extra                     map[string]interface{}      `yaml:",inline"`
+}
+
+// EngineConfig represents the `engine` section of a Falco configuration.
+type EngineConfig struct {
+	Kind       string              `yaml:"kind,omitempty"`
+	Kmod       *KmodEngineConfig   `yaml:"kmod,omitempty"`
+	Ebpf       *EbpfEngineConfig   `yaml:"ebpf,omitempty"`
+	ModernEbpf *ModernEbpfConfig   `yaml:"modern_ebpf,omitempty"`
+	Replay     *ReplayEngineConfig `yaml:"replay,omitempty"`
+	Gvisor     *GvisorEngineConfig `yaml:"gvisor,omitempty"`
+}
+
+// KmodEngineConfig represents the `engine.kmod` section of a Falco configuration.
+type KmodEngineConfig struct {
+	BufSizePreset  *int  `yaml:"buf_size_preset,omitempty"`
+	DropFailedExit *bool `yaml:"drop_failed_exit,omitempty"`
+}
+
+// EbpfEngineConfig represents the `engine.ebpf` section of a Falco configuration.
+type EbpfEngineConfig struct {
+	Probe          string `yaml:"probe,omitempty"`
+	BufSizePreset  *int   `yaml:"buf_size_preset,omitempty"`
+	DropFailedExit *bool  `yaml:"drop_failed_exit,omitempty"`
+}
+
+// ModernEbpfConfig represents the `engine.modern_ebpf` section of a Falco configuration.
+type ModernEbpfConfig struct {
+	CpusForEachBuffer *int  `yaml:"cpus_for_each_buffer,omitempty"`
+	BufSizePreset     *int  `yaml:"buf_size_preset,omitempty"`
+	DropFailedExit    *bool `yaml:"drop_failed_exit,omitempty"`
+}
+
+// ReplayEngineConfig represents the `engine.replay` section of a Falco configuration.
+type ReplayEngineConfig struct {
+	CaptureFile string `yaml:"capture_file,omitempty"`
+}
+
+// GvisorEngineConfig represents the `engine.gvisor` section of a Falco configuration.
+type GvisorEngineConfig struct {
+	Config   string `yaml:"config,omitempty"`
+	RootPath string `yaml:"root,omitempty"`
+}
+
+// PluginConfig represents a plugin entry in the `plugins` section of
+// a Falco configuration. InitConfig can be either a string or
+// a yaml-serializable object.
+type PluginConfig struct {
+	Name        string      `yaml:"name"`
+	LibraryPath string      `yaml:"library_path"`
+	InitConfig  interface{} `yaml:"init_config,omitempty"`
+	OpenParams  string      `yaml:"open_params,omitempty"`
+}
+
+// OutputsConfig represents the `outputs` section of a Falco configuration.
+type OutputsConfig struct {
+	Rate     *float64 `yaml:"rate,omitempty"`
+	MaxBurst *int     `yaml:"max_burst,omitempty"`
+}
+
+// OutputConfig represents the section of a Falco output channel that
+// can only be enabled or disabled (e.g. `stdout_output`).
+type OutputConfig struct {
+	Enabled *bool `yaml:"enabled,omitempty"`
+}
+
+// FileOutputConfig represents the `file_output` section of a Falco configuration.
+type FileOutputConfig struct {
+	Enabled   *bool  `yaml:"enabled,omitempty"`
+	KeepAlive *bool  `yaml:"keep_alive,omitempty"`
+	Filename  string `yaml:"filename,omitempty"`
+}
+
+// HTTPOutputConfig represents the `http_output` section of a Falco configuration.
+type HTTPOutputConfig struct {
+	Enabled         *bool  `yaml:"enabled,omitempty"`
+	URL             string `yaml:"url,omitempty"`
+	UserAgent       string `yaml:"user_agent,omitempty"`
+	Insecure        *bool  `yaml:"insecure,omitempty"`
+	CACert          string `yaml:"ca_cert,omitempty"`
+	CABundle        string `yaml:"ca_bundle,omitempty"`
+	CAPath          string `yaml:"ca_path,omitempty"`
+	MTLS            *bool  `yaml:"mtls,omitempty"`
+	ClientCert      string `yaml:"client_cert,omitempty"`
+	ClientKey       string `yaml:"client_key,omitempty"`
+	EchoEnabled     *bool  `yaml:"echo,omitempty"`
+	CompressUploads *bool  `yaml:"compress_uploads,omitempty"`
+	KeepAlive       *bool  `yaml:"keep_alive,omitempty"`
+}
+
+// ProgramOutputConfig represents the `program_output` section of a Falco configuration.
+type ProgramOutputConfig struct {
+	Enabled   *bool  `yaml:"enabled,omitempty"`
+	KeepAlive *bool  `yaml:"keep_alive,omitempty"`
+	Program   string `yaml:"program,omitempty"`
+}
+
+// GRPCConfig represents the `grpc` section of a Falco configuration.
+type GRPCConfig struct {
+	Enabled     *bool  `yaml:"enabled,omitempty"`
+	BindAddress string `yaml:"bind_address,omitempty"`
+	Threadiness *int   `yaml:"threadiness,omitempty"`
+	PrivateKey  string `yaml:"private_key,omitempty"`
+	CertChain   string `yaml:"cert_chain,omitempty"`
+	RootCerts   string `yaml:"root_certs,omitempty"`
+}
+
+// WebserverConfig represents the `webserver` section of a Falco configuration.
+type WebserverConfig struct {
+	Enabled                  *bool  `yaml:"enabled,omitempty"`
+	Threadiness              *int   `yaml:"threadiness,omitempty"`
+	ListenPort               *int   `yaml:"listen_port,omitempty"`
+	K8sHealthzEndpoint       string `yaml:"k8s_healthz_endpoint,omitempty"`
+	SSLEnabled               *bool  `yaml:"ssl_enabled,omitempty"`
+	SSLCertificate           string `yaml:"ssl_certificate,omitempty"`
+	PrometheusMetricsEnabled *bool  `yaml:"prometheus_metrics_enabled,omitempty"`
+}
+
+// LibsLoggerConfig represents the `libs_logger` section of a Falco configuration.
+type LibsLoggerConfig struct {
+	Enabled  *bool  `yaml:"enabled,omitempty"`
+	Severity string `yaml:"severity,omitempty"`
+}
+
+// SyscallEventTimeoutsConfig represents the `syscall_event_timeouts` section
+// of a Falco configuration.
+type SyscallEventTimeoutsConfig struct {
+	MaxConsecutives *int `yaml:"max_consecutives,omitempty"`
+}
+
+// SyscallEventDropsConfig represents the `syscall_event_drops` section
+// of a Falco configuration.
+type SyscallEventDropsConfig struct {
+	Threshold     *float64 `yaml:"threshold,omitempty"`
+	Actions       []string `yaml:"actions,omitempty"`
+	Rate          *float64 `yaml:"rate,omitempty"`
+	MaxBurst      *int     `yaml:"max_burst,omitempty"`
+	SimulateDrops *bool    `yaml:"simulate_drops,omitempty"`
+}
+
+// MetricsConfig represents the `metrics` section of a Falco configuration.
+type MetricsConfig struct {
+	Enabled                    *bool  `yaml:"enabled,omitempty"`
+	Interval                   string `yaml:"interval,omitempty"`
+	OutputRule                 *bool  `yaml:"output_rule,omitempty"`
+	OutputFile                 string `yaml:"output_file,omitempty"`
+	ResourceUtilizationEnabled *bool  `yaml:"resource_utilization_enabled,omitempty"`
+	StateCountersEnabled       *bool  `yaml:"state_counters_enabled,omitempty"`
+	KernelEventCountersEnabled *bool  `yaml:"kernel_event_counters_enabled,omitempty"`
+	LibbpfStatsEnabled         *bool  `yaml:"libbpf_stats_enabled,omitempty"`
+	ConvertMemoryToMB          *bool  `yaml:"convert_memory_to_mb,omitempty"`
+	IncludeEmptyValues         *bool  `yaml:"include_empty_values,omitempty"`
+}
+
+// BaseSyscallsConfig represents the `base_syscalls` section of a Falco configuration.
+type BaseSyscallsConfig struct {
+	Custom []string `yaml:"custom_set,omitempty"`
+	Repair *bool    `yaml:"repair,omitempty"`
+}
+
+// Bool returns a pointer to the given bool value, useful for setting
+// optional fields of Config.
+func Bool(v bool) *bool {
+	return &v
+}
+
+// Int returns a pointer to the given int value, useful for setting
+// optional fields of Config.
+func Int(v int) *int {
+	return &v
+}
+
+// Float returns a pointer to the given float64 value, useful for setting
+// optional fields of Config.
+func Float(v float64) *float64 {
+	return &v
+}
+
+// YAML serializes the configuration in the YAML format.
+func (c *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Merge returns a new configuration resulting from applying the given
+// overrides on top of the current one, in order. Maps are merged
+// recursively, whereas any other field set in an override, including
+// lists, replaces the one of the current configuration.
+func (c *Config) Merge(overrides ...*Config) (*Config, error) {
+	merged, err := c.toMap()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range overrides {
+		m, err := o.toMap()
+		if err != nil {
+			return nil, err
+		}
+		merged = mergeMaps(merged, m)
+	}
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	res := &Config{}
+	if err := yaml.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// File serializes the configuration into a file accessor with the given
+// name, which can be used with WithConfig.
+func (c *Config) File(name string) (run.FileAccessor, error) {
+	data, err := c.YAML()
+	if err != nil {
+		return nil, err
+	}
+	return run.NewBytesFileAccessor(name, data), nil
+}
+
+func (c *Config) toMap() (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+	data, err := c.YAML()
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func mergeMaps(dst, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		srcMap, srcOk := v.(map[string]interface{})
+		dstMap, dstOk := dst[k].(map[string]interface{})
+		if srcOk && dstOk {
+			dst[k] = mergeMaps(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
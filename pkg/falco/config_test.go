@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigMerge(t *testing.T) {
+	base := &Config{
+		JSONOutput: Bool(true),
+		Engine: &EngineConfig{
+			Kind:   "replay",
+			Replay: &ReplayEngineConfig{CaptureFile: "capture.scap"},
+		},
+		SyscallEventDrops: &SyscallEventDropsConfig{Actions: []string{"log", "alert"}},
+		Extra:             map[string]interface{}{"custom_field": "value"},
+	}
+	merged, err := base.Merge(&Config{
+		JSONOutput:        Bool(false),
+		Engine:            &EngineConfig{Kind: "nodriver"},
+		SyscallEventDrops: &SyscallEventDropsConfig{Actions: []string{"exit"}},
+	})
+	require.Nil(t, err)
+	require.False(t, *merged.JSONOutput)
+	require.Equal(t, "nodriver", merged.Engine.Kind)
+	require.Equal(t, "capture.scap", merged.Engine.Replay.CaptureFile)
+	require.Equal(t, []string{"exit"}, merged.SyscallEventDrops.Actions)
+	require.Equal(t, "value", merged.Extra["custom_field"])
+
+	// the base config must be left untouched
+	require.True(t, *base.JSONOutput)
+	require.Equal(t, "replay", base.Engine.Kind)
+}
+
+func TestConfigFile(t *testing.T) {
+	config := &Config{
+		StdoutOutput: &OutputConfig{Enabled: Bool(false)},
+		Plugins: []PluginConfig{
+			{Name: "dummy", LibraryPath: "libdummy.so", OpenParams: `{"start": 1}`},
+		},
+		LoadPlugins: []string{"dummy"},
+	}
+	f, err := config.File("falco.yaml")
+	require.Nil(t, err)
+	require.Equal(t, "falco.yaml", f.Name())
+	content, err := f.Content()
+	require.Nil(t, err)
+	require.Equal(t, `plugins:
+  - name: dummy
+    library_path: libdummy.so
+    open_params: '{"start": 1}'
+load_plugins:
+  - dummy
+stdout_output:
+  enabled: false
+`, string(content))
+}
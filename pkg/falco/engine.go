@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/falcosecurity/testing/pkg/run"
+	"go.uber.org/multierr"
+)
+
+// EngineKind is a kind of engine used by Falco to collect events.
+type EngineKind string
+
+const (
+	// EngineKmod collects syscall events with the kernel module
+	EngineKmod EngineKind = "kmod"
+	//
+	// EngineEbpf collects syscall events with the legacy eBPF probe
+	EngineEbpf EngineKind = "ebpf"
+	//
+	// EngineModernEbpf collects syscall events with the modern eBPF probe
+	EngineModernEbpf EngineKind = "modern_ebpf"
+	//
+	// EngineReplay reads syscall events from a capture file
+	EngineReplay EngineKind = "replay"
+	//
+	// EngineGvisor collects syscall events from gVisor sandboxes
+	EngineGvisor EngineKind = "gvisor"
+	//
+	// EngineNoDriver runs Falco without any syscall event source driver
+	EngineNoDriver EngineKind = "nodriver"
+	//
+	// EnginePlugin disables the syscall event source, so that events
+	// are collected only from the loaded source plugins
+	EnginePlugin EngineKind = "plugin"
+)
+
+// EngineKinds is the list of all the supported engine kinds
+var EngineKinds = []EngineKind{
+	EngineKmod,
+	EngineEbpf,
+	EngineModernEbpf,
+	EngineReplay,
+	EngineGvisor,
+	EngineNoDriver,
+	EnginePlugin,
+}
+
+// EngineOptions are the options of a Falco engine. Each option is
+// used only by the engine kinds that support it, and zero values
+// leave Falco's defaults unchanged.
+type EngineOptions struct {
+	// BufSizePreset is the preset of the size of the syscall buffers
+	// (kmod, ebpf, modern_ebpf)
+	BufSizePreset *int
+	//
+	// DropFailedExit drops the exit events of failed syscalls
+	// (kmod, ebpf, modern_ebpf)
+	DropFailedExit *bool
+	//
+	// CpusForEachBuffer is the number of CPUs sharing a syscall buffer
+	// (modern_ebpf)
+	CpusForEachBuffer *int
+	//
+	// Probe is the path to the eBPF probe (ebpf)
+	Probe string
+	//
+	// CaptureFile is the capture file from which events are read (replay)
+	CaptureFile run.FileAccessor
+	//
+	// GvisorConfig and GvisorRoot are the paths to the gVisor configuration
+	// file and to the root directory of the gVisor sandboxes (gvisor)
+	GvisorConfig string
+	GvisorRoot   string
+}
+
+// WithEngine runs Falco with a given kind of engine, configured through
+// the `-o engine.*` options. The options can be nil.
+func WithEngine(kind EngineKind, options *EngineOptions) TestOption {
+	return func(o *testOptions) {
+		opts := options
+		if opts == nil {
+			opts = &EngineOptions{}
+		}
+		if kind == EnginePlugin {
+			o.args = append(o.args, "--disable-source", "syscall")
+			return
+		}
+
+		prefix := "engine." + string(kind) + "."
+		o.args = append(o.args, "-o", "engine.kind="+string(kind))
+		switch kind {
+		case EngineKmod, EngineEbpf, EngineModernEbpf:
+			if opts.BufSizePreset != nil {
+				o.args = append(o.args, "-o", prefix+"buf_size_preset="+strconv.Itoa(*opts.BufSizePreset))
+			}
+			if opts.DropFailedExit != nil {
+				o.args = append(o.args, "-o", prefix+"drop_failed_exit="+strconv.FormatBool(*opts.DropFailedExit))
+			}
+			if kind == EngineEbpf && len(opts.Probe) > 0 {
+				o.args = append(o.args, "-o", prefix+"probe="+opts.Probe)
+			}
+			if kind == EngineModernEbpf && opts.CpusForEachBuffer != nil {
+				o.args = append(o.args, "-o", prefix+"cpus_for_each_buffer="+strconv.Itoa(*opts.CpusForEachBuffer))
+			}
+		case EngineReplay:
+			if opts.CaptureFile == nil {
+				o.err = multierr.Append(o.err, fmt.Errorf("engine '%s' requires a capture file", kind))
+				return
+			}
+			o.args = append(o.args, "-o", prefix+"capture_file="+opts.CaptureFile.Name())
+			o.files = append(o.files, opts.CaptureFile)
+		case EngineGvisor:
+			if len(opts.GvisorConfig) > 0 {
+				o.args = append(o.args, "-o", prefix+"config="+opts.GvisorConfig)
+			}
+			if len(opts.GvisorRoot) > 0 {
+				o.args = append(o.args, "-o", prefix+"root="+opts.GvisorRoot)
+			}
+		}
+	}
+}
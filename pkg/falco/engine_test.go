@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"testing"
+
+	"github.com/falcosecurity/testing/pkg/run"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEngine(t *testing.T) {
+	t.Parallel()
+	capture := run.NewStringFileAccessor("capture.scap", "")
+	testCases := []struct {
+		kind    EngineKind
+		options *EngineOptions
+		args    []string
+		files   int
+		err     bool
+	}{
+		{
+			kind: EngineNoDriver,
+			args: []string{"-o", "engine.kind=nodriver"},
+		},
+		{
+			kind:    EngineModernEbpf,
+			options: &EngineOptions{BufSizePreset: Int(4), DropFailedExit: Bool(true), CpusForEachBuffer: Int(2), Probe: "unused"},
+			args: []string{
+				"-o", "engine.kind=modern_ebpf",
+				"-o", "engine.modern_ebpf.buf_size_preset=4",
+				"-o", "engine.modern_ebpf.drop_failed_exit=true",
+				"-o", "engine.modern_ebpf.cpus_for_each_buffer=2",
+			},
+		},
+		{
+			kind:    EngineEbpf,
+			options: &EngineOptions{Probe: "/root/.falco/falco-bpf.o"},
+			args:    []string{"-o", "engine.kind=ebpf", "-o", "engine.ebpf.probe=/root/.falco/falco-bpf.o"},
+		},
+		{
+			kind:    EngineReplay,
+			options: &EngineOptions{CaptureFile: capture},
+			args:    []string{"-o", "engine.kind=replay", "-o", "engine.replay.capture_file=capture.scap"},
+			files:   1,
+		},
+		{
+			kind: EngineReplay,
+			args: []string{"-o", "engine.kind=replay"},
+			err:  true,
+		},
+		{
+			kind:    EngineGvisor,
+			options: &EngineOptions{GvisorConfig: "/etc/runsc.json", GvisorRoot: "/var/run/runsc"},
+			args: []string{
+				"-o", "engine.kind=gvisor",
+				"-o", "engine.gvisor.config=/etc/runsc.json",
+				"-o", "engine.gvisor.root=/var/run/runsc",
+			},
+		},
+		{
+			kind: EnginePlugin,
+			args: []string{"--disable-source", "syscall"},
+		},
+	}
+	for _, tc := range testCases {
+		opts := &testOptions{}
+		WithEngine(tc.kind, tc.options)(opts)
+		assert.Equal(t, tc.args, opts.args, string(tc.kind))
+		assert.Len(t, opts.files, tc.files, string(tc.kind))
+		if tc.err {
+			require.Error(t, opts.err, string(tc.kind))
+		} else {
+			require.NoError(t, opts.err, string(tc.kind))
+		}
+	}
+}
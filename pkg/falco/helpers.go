@@ -19,9 +19,8 @@ limitations under the License.
 package falco
 
 import (
-	"bytes"
 	"encoding/json"
-	"text/template"
+	"fmt"
 
 	"github.com/falcosecurity/testing/pkg/run"
 )
@@ -36,39 +35,40 @@ type PluginConfigInfo struct {
 	InitConfig interface{}
 }
 
-//lint:ignore U1000 this receiver is invoked in a tamplete and is not actually unused
-func (p *PluginConfigInfo) initConfigString() string {
-	if p.InitConfig == nil {
-		return ""
+func (p *PluginConfigInfo) pluginConfig() (*PluginConfig, error) {
+	res := &PluginConfig{
+		Name:        p.Name,
+		LibraryPath: p.Library,
+		OpenParams:  p.OpenParams,
 	}
-	if str, ok := p.InitConfig.(string); ok {
-		return str
+	if p.InitConfig != nil {
+		if str, ok := p.InitConfig.(string); ok {
+			res.InitConfig = str
+		} else {
+			// note: we go through JSON to honor the json tags of the object
+			data, err := json.Marshal(p.InitConfig)
+			if err != nil {
+				return nil, fmt.Errorf("PluginConfigInfo is neither a string or a json-serializable object: %s", err.Error())
+			}
+			if err := json.Unmarshal(data, &res.InitConfig); err != nil {
+				return nil, err
+			}
+		}
 	}
-	str, err := json.Marshal(p.InitConfig)
-	if err != nil {
-		panic("PluginConfigInfo is neither a string or a json-serializable object: " + err.Error())
-	}
-	return string(str)
+	return res, nil
 }
 
 // NewPluginConfig helps creating valid Falco configuration files
 // (i.e. falco.yaml) loading one or more plugins.
 func NewPluginConfig(configName string, plugins ...*PluginConfigInfo) (run.FileAccessor, error) {
-	var buf bytes.Buffer
-	err := template.Must(template.New("").Parse(`
-stdout_output:
-  enabled: true
-plugins:
-{{ range $i, $p := . }}  - name: {{ $p.Name }}
-    library_path: {{ $p.Library }}{{ if $p.InitConfig }}
-    init_config: {{ call $p.initConfigString }}{{ end }}{{ if $p.OpenParams }}
-    open_params: {{ $p.OpenParams }}{{ end }}
-{{ end }}load_plugins:{{ range $i, $p := . }}
-  - {{ $p.Name }}
-{{ end }}
-`)).Execute(&buf, plugins)
-	if err != nil {
-		return nil, err
+	config := &Config{StdoutOutput: &OutputConfig{Enabled: Bool(true)}}
+	for _, p := range plugins {
+		pluginConfig, err := p.pluginConfig()
+		if err != nil {
+			return nil, err
+		}
+		config.Plugins = append(config.Plugins, *pluginConfig)
+		config.LoadPlugins = append(config.LoadPlugins, p.Name)
 	}
-	return run.NewStringFileAccessor(configName, buf.String()), err
+	return config.File(configName)
 }
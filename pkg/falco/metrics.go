@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricSample is a sample of a Prometheus metric
+type MetricSample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	//
+	// Timestamp is the timestamp of the sample in milliseconds
+	// since epoch, or zero if the sample has no timestamp
+	Timestamp int64
+}
+
+// Metrics is a set of Prometheus metrics samples scraped at a given time
+type Metrics struct {
+	Time    time.Time
+	Samples []*MetricSample
+	//
+	// Types and Help are the types and the help strings of
+	// the metrics, indexed by metric name
+	Types map[string]string
+	Help  map[string]string
+}
+
+// ParseMetrics parses Prometheus metrics in the text exposition format.
+func ParseMetrics(text string) (*Metrics, error) {
+	res := &Metrics{
+		Time:  time.Now(),
+		Types: make(map[string]string),
+		Help:  make(map[string]string),
+	}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				res.Types[fields[2]] = fields[3]
+			} else if len(fields) >= 3 && fields[1] == "HELP" {
+				res.Help[fields[2]] = ""
+				if len(fields) == 4 {
+					res.Help[fields[2]] = fields[3]
+				}
+			}
+			continue
+		}
+		s, err := parseMetricSample(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metrics sample at line %d: %s", i+1, err.Error())
+		}
+		res.Samples = append(res.Samples, s)
+	}
+	return res, nil
+}
+
+func parseMetricSample(line string) (*MetricSample, error) {
+	res := &MetricSample{Labels: make(map[string]string)}
+	end := strings.IndexAny(line, "{ \t")
+	if end <= 0 {
+		return nil, fmt.Errorf("missing value")
+	}
+	res.Name = line[:end]
+	line = line[end:]
+	if strings.HasPrefix(line, "{") {
+		var err error
+		if line, err = parseMetricLabels(line[1:], res.Labels); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 2 {
+		return nil, fmt.Errorf("invalid value")
+	}
+	var err error
+	if res.Value, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return nil, err
+	}
+	if len(fields) == 2 {
+		if res.Timestamp, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// parseMetricLabels parses the labels of a sample up to the closing
+// brace, and returns the rest of the line
+func parseMetricLabels(line string, labels map[string]string) (string, error) {
+	for {
+		line = strings.TrimLeft(line, " \t,")
+		if strings.HasPrefix(line, "}") {
+			return line[1:], nil
+		}
+		eq := strings.Index(line, "=")
+		if eq <= 0 || len(line) < eq+2 || line[eq+1] != '"' {
+			return "", fmt.Errorf("invalid label")
+		}
+		name := strings.TrimSpace(line[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(line) && line[i] != '"'; i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+				switch line[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(line[i])
+				}
+				continue
+			}
+			value.WriteByte(line[i])
+		}
+		if i >= len(line) {
+			return "", fmt.Errorf("unterminated label value")
+		}
+		labels[name] = value.String()
+		line = line[i+1:]
+	}
+}
+
+// matches returns true if the sample has all the given labels
+func (s *MetricSample) matches(labels map[string]string) bool {
+	for k, v := range labels {
+		if l, ok := s.Labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns all the samples of the metric with the given name that
+// have all the given labels. The labels can be nil.
+func (m *Metrics) Query(name string, labels map[string]string) []*MetricSample {
+	var res []*MetricSample
+	for _, s := range m.Samples {
+		if s.Name == name && s.matches(labels) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// Value returns the sum of the values of all the samples of the metric
+// with the given name that have all the given labels, and false if no
+// such sample exists. The labels can be nil.
+func (m *Metrics) Value(name string, labels map[string]string) (float64, bool) {
+	samples := m.Query(name, labels)
+	res := float64(0)
+	for _, s := range samples {
+		res += s.Value
+	}
+	return res, len(samples) > 0
+}
+
+// Has returns true if at least one sample of the metric
+// with the given name exists.
+func (m *Metrics) Has(name string) bool {
+	return len(m.Query(name, nil)) > 0
+}
+
+// Names returns the sorted names of all the metrics with a
+// sample that start with the given prefix.
+func (m *Metrics) Names(prefix string) []string {
+	names := make(map[string]bool)
+	for _, s := range m.Samples {
+		if strings.HasPrefix(s.Name, prefix) {
+			names[s.Name] = true
+		}
+	}
+	var res []string
+	for n := range names {
+		res = append(res, n)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Missing returns the names among the given ones that
+// don't have any sample.
+func (m *Metrics) Missing(names ...string) []string {
+	var res []string
+	for _, n := range names {
+		if !m.Has(n) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// MetricsScrapes is a time series of scraped Prometheus metrics,
+// ordered by scrape time.
+type MetricsScrapes []*Metrics
+
+// First returns the first scrape, or nil if there are none.
+func (s MetricsScrapes) First() *Metrics {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
+
+// Last returns the last scrape, or nil if there are none.
+func (s MetricsScrapes) Last() *Metrics {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[len(s)-1]
+}
+
+// Delta returns the difference between the values of a metric in the
+// last and in the first scrape (see Metrics.Value), which is useful for
+// checking how much a counter increased during a run. Returns zero if
+// the metric is not available in both scrapes.
+func (s MetricsScrapes) Delta(name string, labels map[string]string) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	first, ok := s.First().Value(name, labels)
+	if !ok {
+		return 0
+	}
+	last, ok := s.Last().Value(name, labels)
+	if !ok {
+		return 0
+	}
+	return last - first
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMetrics = `# HELP falcosecurity_falco_version_info https://falco.org/docs/metrics/
+# TYPE falcosecurity_falco_version_info gauge
+falcosecurity_falco_version_info{version="0.37.0"} 1
+# HELP falcosecurity_scap_n_evts_total https://falco.org/docs/metrics/
+# TYPE falcosecurity_scap_n_evts_total counter
+falcosecurity_scap_n_evts_total{raw_name="n_evts"} 100
+# TYPE falcosecurity_falco_rules_matches_total counter
+falcosecurity_falco_rules_matches_total{priority="4",rule_name="rule_a",source="syscall"} 3
+falcosecurity_falco_rules_matches_total{priority="4",rule_name="rule_b, \"quoted\"\\n",source="syscall"} 2 1700000000000
+falcosecurity_falco_duration_seconds_total 1.5e+01
+falcosecurity_falco_sys_inf +Inf
+`
+
+func TestParseMetrics(t *testing.T) {
+	t.Parallel()
+	m, err := ParseMetrics(testMetrics)
+	require.NoError(t, err)
+	require.Len(t, m.Samples, 6)
+	assert.Equal(t, "counter", m.Types["falcosecurity_scap_n_evts_total"])
+	assert.Equal(t, "https://falco.org/docs/metrics/", m.Help["falcosecurity_falco_version_info"])
+
+	assert.True(t, m.Has("falcosecurity_falco_version_info"))
+	assert.Equal(t, "0.37.0", m.Query("falcosecurity_falco_version_info", nil)[0].Labels["version"])
+	assert.Empty(t, m.Missing("falcosecurity_scap_n_evts_total", "falcosecurity_falco_duration_seconds_total"))
+	assert.Equal(t, []string{"falcosecurity_falco_cpu_usage_ratio"}, m.Missing("falcosecurity_falco_cpu_usage_ratio"))
+	assert.Equal(t, []string{"falcosecurity_scap_n_evts_total"}, m.Names("falcosecurity_scap_"))
+
+	v, ok := m.Value("falcosecurity_falco_rules_matches_total", nil)
+	assert.True(t, ok)
+	assert.Equal(t, float64(5), v)
+	v, ok = m.Value("falcosecurity_falco_rules_matches_total", map[string]string{"rule_name": "rule_a"})
+	assert.True(t, ok)
+	assert.Equal(t, float64(3), v)
+	_, ok = m.Value("falcosecurity_falco_rules_matches_total", map[string]string{"rule_name": "rule_c"})
+	assert.False(t, ok)
+
+	quoted := m.Query("falcosecurity_falco_rules_matches_total", map[string]string{"rule_name": "rule_b, \"quoted\"\\n"})
+	require.Len(t, quoted, 1)
+	assert.Equal(t, int64(1700000000000), quoted[0].Timestamp)
+	v, _ = m.Value("falcosecurity_falco_duration_seconds_total", nil)
+	assert.Equal(t, float64(15), v)
+	v, _ = m.Value("falcosecurity_falco_sys_inf", nil)
+	assert.True(t, math.IsInf(v, 1))
+
+	for _, invalid := range []string{"no_value", `m{l="v} 1`, `m{l=v} 1`, "m abc", "m 1 2 3"} {
+		_, err := ParseMetrics(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestMetricsScrapes(t *testing.T) {
+	t.Parallel()
+	var scrapes MetricsScrapes
+	assert.Nil(t, scrapes.First())
+	assert.Nil(t, scrapes.Last())
+	assert.Equal(t, float64(0), scrapes.Delta("falcosecurity_scap_n_evts_total", nil))
+
+	for _, text := range []string{
+		`falcosecurity_scap_n_evts_total 100`,
+		`falcosecurity_scap_n_evts_total 150`,
+		`falcosecurity_scap_n_evts_total 250`,
+	} {
+		m, err := ParseMetrics(text)
+		require.NoError(t, err)
+		scrapes = append(scrapes, m)
+	}
+	assert.Equal(t, float64(150), scrapes.Delta("falcosecurity_scap_n_evts_total", nil))
+	assert.Equal(t, float64(0), scrapes.Delta("falcosecurity_unknown", nil))
+}
@@ -21,6 +21,8 @@ package falco
 import (
 	"bytes"
 	"context"
+	"os"
+	"regexp"
 	"time"
 
 	"github.com/falcosecurity/testing/pkg/run"
@@ -47,23 +49,46 @@ const (
 	//
 	// DefaultConfigFile is the default path of the Falco config file
 	DefaultConfigFile = "/etc/falco/falco.yaml"
+	//
+	// DefaultReadyTimeout is the default max duration for which a running
+	// Falco is waited to become ready
+	DefaultReadyTimeout = time.Second * 30
 )
 
 type testOptions struct {
-	err      error
-	args     []string
-	files    []run.FileAccessor
-	runOpts  []run.RunnerOption
-	duration time.Duration
-	ctx      context.Context
+	err               error
+	args              []string
+	files             []run.FileAccessor
+	runOpts           []run.RunnerOption
+	duration          time.Duration
+	ctx               context.Context
+	signals           []scheduledSignal
+	readyConditions   []ReadyCondition
+	readyTimeout      time.Duration
+	textOutputFormats []*regexp.Regexp
+	grpc              *grpcOptions
+	setups            []func(run.Runner, *testOptions) error
+	programOutput     string
+	metrics           bool
+	metricsInterval   time.Duration
+	webserver         *webserverOptions
+	tcpPorts          []int
+}
+
+type scheduledSignal struct {
+	delay  time.Duration
+	signal os.Signal
 }
 
 // TestOutput is the output of a Falco test run
 type TestOutput struct {
-	opts   *testOptions
-	err    error
-	stdout bytes.Buffer
-	stderr bytes.Buffer
+	opts    *testOptions
+	err     error
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+	files   []run.FileAccessor
+	grpc    *GRPCClient
+	metrics MetricsScrapes
 }
 
 // TestOption is an option for testing Falco
@@ -72,41 +97,73 @@ type TestOption func(*testOptions)
 // Test runs a Falco runner with the given test options, and produces
 // an output representing the outcome of the run.
 func Test(runner run.Runner, options ...TestOption) *TestOutput {
-	res := &TestOutput{
-		opts: &testOptions{
-			duration: DefaultMaxDuration,
-			ctx:      context.Background(),
+	return Start(runner, options...).Wait()
+}
+
+// Start runs a Falco runner with the given test options asynchronously, and
+// returns a handle for interacting with the running Falco process. The output
+// representing the outcome of the run is produced by TestProcess.Wait.
+func Start(runner run.Runner, options ...TestOption) *TestProcess {
+	res := &TestProcess{
+		output: &TestOutput{
+			opts: &testOptions{
+				duration:        DefaultMaxDuration,
+				ctx:             context.Background(),
+				readyTimeout:    DefaultReadyTimeout,
+				metricsInterval: DefaultMetricsScrapeInterval,
+			},
 		},
 	}
-	
+	opts := res.output.opts
+
 	// enforce Falco config path as default
-	res.opts.args = append(res.opts.args, "-c", FalcoConfig)
+	opts.args = append(opts.args, "-c", FalcoConfig)
 
 	for _, o := range options {
-		o(res.opts)
+		o(opts)
 	}
-	if res.opts.err != nil {
+	if opts.err != nil {
 		return res
 	}
+	// some options can only be applied once the runner is known
+	for _, setup := range opts.setups {
+		if opts.err = setup(runner, opts); opts.err != nil {
+			return res
+		}
+	}
 
 	// enforce logging everything on stdout
-	res.opts.args = append(res.opts.args, "-o", "log_level=debug")
-	res.opts.args = append(res.opts.args, "-o", "log_stderr=true")
-	res.opts.args = append(res.opts.args, "-o", "log_syslog=false")
-	res.opts.args = append(res.opts.args, "-o", "stdout_output.enabled=true")
-	logrus.WithField("deadline", res.opts.duration).Info("running falco with runner")
-	ctx, cancel := context.WithTimeout(res.opts.ctx, skewedDuration(res.opts.duration))
-	defer cancel()
-	res.err = runner.Run(ctx,
+	opts.args = append(opts.args, "-o", "log_level=debug")
+	opts.args = append(opts.args, "-o", "log_stderr=true")
+	opts.args = append(opts.args, "-o", "log_syslog=false")
+	opts.args = append(opts.args, "-o", "stdout_output.enabled=true")
+	logrus.WithField("deadline", opts.duration).Info("running falco with runner")
+	var ctx context.Context
+	ctx, res.cancel = context.WithTimeout(opts.ctx, skewedDuration(opts.duration))
+	res.process, res.startErr = runner.Start(ctx,
 		append([]run.RunnerOption{
-			run.WithArgs(res.opts.args...),
-			run.WithFiles(res.opts.files...),
-			run.WithStdout(&res.stdout),
-			run.WithStderr(&res.stderr),
-		}, res.opts.runOpts...)...,
+			run.WithArgs(opts.args...),
+			run.WithFiles(opts.files...),
+			run.WithStdout(&res.output.stdout),
+			run.WithStderr(&res.output.stderr),
+		}, opts.runOpts...)...,
 	)
-	if res.err != nil {
-		logrus.WithError(res.err).Warn("error running falco with runner")
+	if res.startErr != nil {
+		logrus.WithError(res.startErr).Warn("error running falco with runner")
+		return res
+	}
+	res.watch()
+	if opts.metrics {
+		res.scrapeMetrics(ctx, opts.metricsInterval)
+	}
+	for _, s := range opts.signals {
+		res.scheduleSignal(ctx, s)
+	}
+	if len(opts.readyConditions) > 0 {
+		res.readyErr = res.waitReady(opts.readyConditions, opts.readyTimeout)
+		if res.readyErr != nil {
+			logrus.WithError(res.readyErr).Warn("error waiting for falco to be ready")
+		}
 	}
 	return res
 }
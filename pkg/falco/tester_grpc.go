@@ -0,0 +1,375 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/falcosecurity/client-go/pkg/api/outputs"
+	"github.com/falcosecurity/client-go/pkg/api/version"
+	"github.com/falcosecurity/client-go/pkg/client"
+	"github.com/falcosecurity/testing/pkg/certs"
+	"github.com/falcosecurity/testing/pkg/run"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+)
+
+const (
+	// grpcWatchInterval is the frequency with which the gRPC outputs
+	// stream is polled for new alerts
+	grpcWatchInterval = 100 * time.Millisecond
+	//
+	// grpcDialTimeout is the max duration of each attempt of
+	// connecting to the Falco gRPC server
+	grpcDialTimeout = time.Second
+)
+
+// GRPCTransport is the transport used by the Falco gRPC server.
+type GRPCTransport string
+
+const (
+	// GRPCUnix serves gRPC through a unix socket, without TLS
+	GRPCUnix GRPCTransport = "unix"
+	//
+	// GRPCTCP serves gRPC through a TCP port on the loopback interface,
+	// which Falco always protects with mutual TLS
+	GRPCTCP GRPCTransport = "tcp"
+)
+
+type grpcOptions struct {
+	transport GRPCTransport
+	localDir  string
+	config    client.Config
+}
+
+// WithGRPCOutput runs Falco with the gRPC server and the gRPC output enabled,
+// by using the given transport. Falco is considered ready only once its gRPC
+// server accepts connections, after which a client is available through
+// TestProcess.GRPC and TestOutput.GRPC. With GRPCTCP, Falco listens on a TCP
+// port that is free when it is started, which is made reachable from the host
+// with run.WithPorts, and certificates for mutual TLS authentication are
+// generated for both Falco and the client. With GRPCUnix, TLS is not used and
+// the socket must be reachable from the host, so it should be used with
+// runners that share the host's filesystem (e.g. the executable runner). In
+// both cases, the resolved address is available through TestOutput.GRPCAddress.
+func WithGRPCOutput(transport GRPCTransport) TestOption {
+	return func(o *testOptions) {
+		if transport != GRPCUnix && transport != GRPCTCP {
+			o.err = multierr.Append(o.err, fmt.Errorf("unknown gRPC transport '%s'", transport))
+			return
+		}
+		o.grpc = &grpcOptions{transport: transport}
+		o.setups = append(o.setups, o.grpc.setup)
+	}
+}
+
+// setup configures Falco for serving gRPC with the given runner,
+// and prepares the config of the client connecting to it
+func (g *grpcOptions) setup(runner run.Runner, o *testOptions) error {
+	o.readyConditions = append(o.readyConditions, &grpcServerCondition{options: g})
+	o.args = append(o.args, "-o", "grpc.enabled=true")
+	o.args = append(o.args, "-o", "grpc_output.enabled=true")
+	if g.transport == GRPCUnix {
+		socket := path.Join(runner.WorkDir(), "falco.sock")
+		o.args = append(o.args, "-o", "grpc.bind_address=unix://"+socket)
+		g.config.UnixSocketPath = "unix://" + socket
+		return nil
+	}
+
+	port, err := o.reserveTCPPort()
+	if err != nil {
+		return err
+	}
+	// note: binding all the interfaces allows publishing the port
+	// when Falco runs in a container
+	o.args = append(o.args, "-o", "grpc.bind_address=0.0.0.0:"+strconv.Itoa(port))
+	o.runOpts = append(o.runOpts, run.WithPorts(port))
+	g.config.Hostname = "127.0.0.1"
+	g.config.Port = uint16(port)
+
+	// the client reads its certificates from the local filesystem, so we
+	// store them in a temporary directory removed once Falco is waited
+	bundle, err := certs.Generate()
+	if err != nil {
+		return err
+	}
+	g.localDir, err = os.MkdirTemp("", "falcosecurity-testing-grpc-")
+	if err != nil {
+		return err
+	}
+	g.config.CertFile = filepath.Join(g.localDir, "client.crt")
+	g.config.KeyFile = filepath.Join(g.localDir, "client.key")
+	g.config.CARootFile = filepath.Join(g.localDir, "ca.crt")
+	for name, content := range map[string][]byte{
+		g.config.CertFile:   bundle.ClientCert,
+		g.config.KeyFile:    bundle.ClientKey,
+		g.config.CARootFile: bundle.CACert,
+	} {
+		if err := os.WriteFile(name, content, 0600); err != nil {
+			return err
+		}
+	}
+	for opt, f := range map[string]run.FileAccessor{
+		"grpc.private_key": run.NewBytesFileAccessor("grpc/server.key", bundle.ServerKey),
+		"grpc.cert_chain":  run.NewBytesFileAccessor("grpc/server.crt", bundle.ServerCert),
+		"grpc.root_certs":  run.NewBytesFileAccessor("grpc/ca.crt", bundle.CACert),
+	} {
+		o.args = append(o.args, "-o", opt+"="+path.Join(runner.WorkDir(), f.Name()))
+		o.files = append(o.files, f)
+	}
+	return nil
+}
+
+// cleanup removes all the local resources used by the client
+func (g *grpcOptions) cleanup() error {
+	if len(g.localDir) > 0 {
+		return os.RemoveAll(g.localDir)
+	}
+	return nil
+}
+
+// GRPCClient is a client connected to the gRPC server of a running Falco.
+type GRPCClient struct {
+	m        sync.Mutex
+	client   *client.Client
+	received Detections
+}
+
+// Watch subscribes to the gRPC outputs stream of Falco and calls the
+// callback for each received alert, until the context is done or
+// the callback returns a non-nil error, which is then returned.
+func (g *GRPCClient) Watch(ctx context.Context, cb func(*Alert) error) error {
+	return g.client.OutputsWatch(ctx, func(res *outputs.Response) error {
+		alert := grpcResponseToAlert(res)
+		g.m.Lock()
+		g.received = append(g.received, alert)
+		g.m.Unlock()
+		return cb(alert)
+	}, grpcWatchInterval)
+}
+
+// Detections watches the gRPC outputs stream of Falco until the given
+// amount of alerts is received, or until the context is done.
+func (g *GRPCClient) Detections(ctx context.Context, count int) (Detections, error) {
+	errDone := errors.New("received all detections")
+	res := make(Detections, 0, count)
+	if count <= 0 {
+		return res, nil
+	}
+	err := g.Watch(ctx, func(a *Alert) error {
+		res = append(res, a)
+		if len(res) >= count {
+			return errDone
+		}
+		return nil
+	})
+	if err == errDone {
+		err = nil
+	}
+	return res, err
+}
+
+// Received returns all the alerts received through the gRPC client so far.
+func (g *GRPCClient) Received() Detections {
+	g.m.Lock()
+	defer g.m.Unlock()
+	return append(Detections{}, g.received...)
+}
+
+// Version returns the version of Falco through the gRPC version service.
+func (g *GRPCClient) Version(ctx context.Context) (*version.Response, error) {
+	vc, err := g.client.Version()
+	if err != nil {
+		return nil, err
+	}
+	return vc.Version(ctx, &version.Request{})
+}
+
+// Close closes the connection to the Falco gRPC server.
+func (g *GRPCClient) Close() error {
+	return g.client.Close()
+}
+
+// attach makes the client available through the given process
+func (g *GRPCClient) attach(p *TestProcess) {
+	p.output.grpc = g
+}
+
+// release closes the client when Falco does not become ready
+func (g *GRPCClient) release() {
+	if err := g.Close(); err != nil {
+		logrus.WithError(err).Debug("can't close falco grpc client")
+	}
+}
+
+// GRPC returns the gRPC client connected to Falco, which is available
+// once Falco is ready. Returns nil if Falco wasn't run with WithGRPCOutput.
+func (p *TestProcess) GRPC() *GRPCClient {
+	return p.output.grpc
+}
+
+// GRPC returns the gRPC client that was connected to Falco during the run.
+// The connection is closed once Falco exits, so only the alerts already
+// received are available through it. Returns nil if Falco wasn't run
+// with WithGRPCOutput.
+func (t *TestOutput) GRPC() *GRPCClient {
+	return t.grpc
+}
+
+// GRPCAddress returns the address of the gRPC server of Falco, which is
+// either a "unix://" socket path or a "host:port" TCP address. Returns an
+// empty string if Falco wasn't run with WithGRPCOutput, or if it could
+// not be started.
+func (t *TestOutput) GRPCAddress() string {
+	switch {
+	case t.opts.grpc == nil:
+		return ""
+	case len(t.opts.grpc.config.UnixSocketPath) > 0:
+		return t.opts.grpc.config.UnixSocketPath
+	case t.opts.grpc.config.Port > 0:
+		return net.JoinHostPort(t.opts.grpc.config.Hostname, strconv.Itoa(int(t.opts.grpc.config.Port)))
+	default:
+		return ""
+	}
+}
+
+type grpcServerCondition struct {
+	options *grpcOptions
+}
+
+func (g *grpcServerCondition) Wait(ctx context.Context, p *TestProcess) error {
+	res, err := g.acquire(ctx, p)
+	if err != nil {
+		return err
+	}
+	res.release()
+	return nil
+}
+
+// acquire waits for the gRPC server to accept connections, and returns
+// a client connected to it
+func (g *grpcServerCondition) acquire(ctx context.Context, p *TestProcess) (readyResource, error) {
+	var cli *GRPCClient
+	err := poll(ctx, func() (bool, error) {
+		dialCtx, cancel := context.WithTimeout(ctx, grpcDialTimeout)
+		defer cancel()
+		// note: connections fail until the server is up
+		c, err := client.NewForConfig(dialCtx, &g.options.config)
+		if err != nil {
+			return false, nil
+		}
+		res := &GRPCClient{client: c}
+		if _, err := res.Version(dialCtx); err != nil {
+			c.Close()
+			return false, nil
+		}
+		cli = res
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
+
+func (g *grpcServerCondition) String() string {
+	return fmt.Sprintf("gRPC server is up (%s)", g.options.transport)
+}
+
+func grpcResponseToAlert(res *outputs.Response) *Alert {
+	outputFields := make(map[string]interface{})
+	for k, v := range res.OutputFields {
+		outputFields[k] = v
+	}
+	return &Alert{
+		Time:         res.Time.AsTime(),
+		Rule:         res.Rule,
+		Output:       res.Output,
+		Priority:     res.Priority.String(),
+		Source:       res.Source,
+		Hostname:     res.Hostname,
+		Tags:         res.Tags,
+		OutputFields: outputFields,
+	}
+}
+
+// freeTCPPortAttempts is the max number of ports requested to the kernel
+// when looking for one that is not reserved already
+const freeTCPPortAttempts = 100
+
+var (
+	freeTCPPortsMu sync.Mutex
+	freeTCPPorts   = make(map[int]bool)
+)
+
+// freeTCPPort returns a TCP port that is currently free on the loopback
+// interface. The port is released before returning so that Falco can bind
+// it, which means that another process may bind it first. This can't be
+// prevented without handing the listening socket to Falco, but the port is
+// reserved and not returned again until released with releaseTCPPorts, so
+// that at least the Falco instances run concurrently by the same tests
+// don't conflict.
+func freeTCPPort() (int, error) {
+	freeTCPPortsMu.Lock()
+	defer freeTCPPortsMu.Unlock()
+	for i := 0; i < freeTCPPortAttempts; i++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, err
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		if err := l.Close(); err != nil {
+			return 0, err
+		}
+		if !freeTCPPorts[port] {
+			freeTCPPorts[port] = true
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("can't find a free tcp port after %d attempts", freeTCPPortAttempts)
+}
+
+// releaseTCPPorts makes the given ports available again to freeTCPPort
+func releaseTCPPorts(ports ...int) {
+	freeTCPPortsMu.Lock()
+	defer freeTCPPortsMu.Unlock()
+	for _, port := range ports {
+		delete(freeTCPPorts, port)
+	}
+}
+
+// reserveTCPPort returns a free TCP port for Falco, which is
+// released once Falco is waited
+func (o *testOptions) reserveTCPPort() (int, error) {
+	port, err := freeTCPPort()
+	if err != nil {
+		return 0, err
+	}
+	o.tcpPorts = append(o.tcpPorts, port)
+	return port, nil
+}
@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithGRPCOutput(t *testing.T) {
+	t.Parallel()
+	for _, transport := range []GRPCTransport{GRPCUnix, GRPCTCP} {
+		opts := &testOptions{}
+		WithGRPCOutput(transport)(opts)
+		require.NoError(t, opts.err)
+		require.NotNil(t, opts.grpc)
+		assert.Equal(t, transport, opts.grpc.transport)
+	}
+
+	opts := &testOptions{}
+	WithGRPCOutput("udp")(opts)
+	assert.Error(t, opts.err)
+	assert.Nil(t, opts.grpc)
+}
+
+func TestFreeTCPPort(t *testing.T) {
+	t.Parallel()
+	ports := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		port, err := freeTCPPort()
+		require.NoError(t, err)
+		assert.False(t, ports[port], "port %d returned twice", port)
+		ports[port] = true
+	}
+
+	// released ports can be returned again
+	for port := range ports {
+		releaseTCPPorts(port)
+		freeTCPPortsMu.Lock()
+		assert.False(t, freeTCPPorts[port])
+		freeTCPPortsMu.Unlock()
+	}
+
+	// the ports reserved for Falco are released once it is waited
+	p := Start(&stubRunner{exitAfter: time.Millisecond}, WithWebserver())
+	require.NoError(t, p.Err())
+	port := p.output.opts.webserver.port
+	freeTCPPortsMu.Lock()
+	assert.True(t, freeTCPPorts[port])
+	freeTCPPortsMu.Unlock()
+	p.Wait()
+	freeTCPPortsMu.Lock()
+	assert.False(t, freeTCPPorts[port])
+	freeTCPPortsMu.Unlock()
+}
@@ -21,6 +21,8 @@ package falco
 import (
 	"context"
 	"fmt"
+	"os"
+	"regexp"
 	"time"
 
 	"github.com/falcosecurity/testing/pkg/run"
@@ -85,16 +87,29 @@ func WithDisabledSources(sources ...string) TestOption {
 }
 
 // WithPrometheusMetrics runs Falco enabling prometheus metrics endpoint.
+// The metrics are served by the webserver of Falco (see WithWebserver) at
+// the URL returned by TestOutput.MetricsURL, are periodically scraped during
+// the run, and are then available through TestOutput.Metrics.
 func WithPrometheusMetrics() TestOption {
 	return func(o *testOptions) {
+		WithWebserver()(o)
+		o.metrics = true
 		o.args = append(o.args, "-o", "metrics.enabled=true")
 		o.args = append(o.args, "-o", "metrics.output_rule=true")
 		o.args = append(o.args, "-o", "metrics.interval=2s")
-		o.args = append(o.args, "-o", "webserver.enabled=true")
 		o.args = append(o.args, "-o", "webserver.prometheus_metrics_enabled=true")
 	}
 }
 
+// WithMetricsScrapeInterval sets the interval with which the Prometheus
+// metrics are scraped when running Falco with WithPrometheusMetrics.
+// Each scrape times out after the same interval.
+func WithMetricsScrapeInterval(interval time.Duration) TestOption {
+	return func(o *testOptions) {
+		o.metricsInterval = interval
+	}
+}
+
 // WithMinRulePriority runs Falco by forcing a mimimum rules priority.
 func WithMinRulePriority(priority string) TestOption {
 	return func(o *testOptions) {
@@ -118,10 +133,7 @@ func WithAllEvents() TestOption {
 
 // WithCaptureFile runs Falco reading events from a capture file through the `-o engine.kind=replay` option.
 func WithCaptureFile(f run.FileAccessor) TestOption {
-	return func(o *testOptions) {
-		o.args = append(o.args, "-o", "engine.kind=replay", "-o", fmt.Sprintf("engine.replay.capture_file=%s", f.Name()))
-		o.files = append(o.files, f)
-	}
+	return WithEngine(EngineReplay, &EngineOptions{CaptureFile: f})
 }
 
 // WithContextDeadline runs Falco with a maximum context deadline.
@@ -157,6 +169,15 @@ func WithEnvVars(vars map[string]string) TestOption {
 	}
 }
 
+// WithOutputFiles collects the files with the given paths produced by Falco
+// during the run, which are then available through TestOutput.File.
+// Relative paths are resolved from the working directory of the runner.
+func WithOutputFiles(paths ...string) TestOption {
+	return func(o *testOptions) {
+		o.runOpts = append(o.runOpts, run.WithOutputFiles(paths...))
+	}
+}
+
 // WithContext runs Falco with a given context.
 func WithContext(ctx context.Context) TestOption {
 	return func(o *testOptions) { o.ctx = ctx }
@@ -169,3 +190,40 @@ func WithStopAfter(duration time.Duration) TestOption {
 		o.args = append(o.args, "-M", fmt.Sprintf("%d", int64(duration.Seconds())))
 	}
 }
+
+// WithSignalAt sends a signal to Falco after 'delay' since its start.
+// The option can be used multiple times for sending more signals.
+// When running with a Docker runner, signals are delivered to the container.
+func WithSignalAt(delay time.Duration, signal os.Signal) TestOption {
+	return func(o *testOptions) {
+		o.signals = append(o.signals, scheduledSignal{delay: delay, signal: signal})
+	}
+}
+
+// WithReadyWhen makes Falco be considered ready only once all the given
+// conditions are satisfied. When running Falco with Start, the function
+// returns only after Falco becomes ready or fails to.
+func WithReadyWhen(conds ...ReadyCondition) TestOption {
+	return func(o *testOptions) {
+		o.readyConditions = append(o.readyConditions, conds...)
+	}
+}
+
+// WithReadyTimeout sets the max duration for which a running Falco
+// is waited to become ready.
+func WithReadyTimeout(duration time.Duration) TestOption {
+	return func(o *testOptions) {
+		o.readyTimeout = duration
+	}
+}
+
+// WithTextOutputFormat makes the alerts printed by Falco in text format
+// be parsed with the given regular expressions, for example when using a custom
+// output format or the `-p` option. The named groups "time", "priority",
+// "rule", "source", "hostname", and "tags" (comma-separated) are used for
+// populating the parsed alerts, and the whole line is used as their output.
+func WithTextOutputFormat(formats ...*regexp.Regexp) TestOption {
+	return func(o *testOptions) {
+		o.textOutputFormats = append(o.textOutputFormats, formats...)
+	}
+}
@@ -21,6 +21,8 @@ package falco
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"syscall"
 
 	"github.com/falcosecurity/testing/pkg/run"
 	"github.com/sirupsen/logrus"
@@ -54,12 +56,49 @@ func (t *TestOutput) DurationExceeded() bool {
 
 // ExitCode returns the numeric exit code of the Falco process.
 func (t *TestOutput) ExitCode() int {
+	if exitCodeErr := t.exitCodeError(); exitCodeErr != nil {
+		return exitCodeErr.Code
+	}
+	return 0
+}
+
+// TerminationSignal returns the signal that terminated the Falco process,
+// or 0 if the process exited on its own.
+func (t *TestOutput) TerminationSignal() syscall.Signal {
+	if exitCodeErr := t.exitCodeError(); exitCodeErr != nil {
+		return exitCodeErr.Signal
+	}
+	return 0
+}
+
+// OOMKilled returns true if the Falco process has been killed
+// for exceeding its memory limit.
+func (t *TestOutput) OOMKilled() bool {
+	if exitCodeErr := t.exitCodeError(); exitCodeErr != nil {
+		return exitCodeErr.OOMKilled
+	}
+	return false
+}
+
+func (t *TestOutput) exitCodeError() *run.ExitCodeError {
 	for _, err := range multierr.Errors(t.Err()) {
 		if exitCodeErr, ok := err.(*run.ExitCodeError); ok {
-			return exitCodeErr.Code
+			return exitCodeErr
 		}
 	}
-	return 0
+	return nil
+}
+
+// File returns the content of a file produced by the Falco run and collected
+// with WithOutputFiles, named with the same path used when collecting it.
+// Returns a non-nil error if the file was not collected.
+func (t *TestOutput) File(name string) ([]byte, error) {
+	for _, f := range t.files {
+		if f.Name() == name {
+			return f.Content()
+		}
+	}
+	return nil, fmt.Errorf("output file '%s' was not collected", name)
 }
 
 // Stdout returns a string containing the stdout output of the Falco run.
@@ -0,0 +1,368 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// versionArchRegex matches the architecture printed
+	// after the version of Falco (e.g. "0.37.0 (x86_64)")
+	versionArchRegex = regexp.MustCompile(`\s+\(.*\)$`)
+	//
+	// eventSourcingRegex matches the event sourcing capability of a
+	// plugin (e.g. "Event Sourcing (ID=2, source='aws_cloudtrail')")
+	eventSourcingRegex = regexp.MustCompile(`^Event Sourcing \(ID=([0-9]+), source='([^']*)'\)`)
+	//
+	// fieldClassRegex matches the name and the short description of a
+	// field class (e.g. "cloudtrail (Fields for CloudTrail events)")
+	fieldClassRegex = regexp.MustCompile(`^(\S+) \((.*)\)$`)
+	//
+	// fieldInfoRegex matches the name and the description of a field
+	// (e.g. "ct.id          the unique ID of the cloudtrail event")
+	fieldInfoRegex = regexp.MustCompile(`^([a-zA-Z0-9_]+\.\S+)(?:\s+(.*))?$`)
+)
+
+// PluginCapabilities are the capabilities of a plugin
+type PluginCapabilities struct {
+	EventSourcing   bool `json:"event_sourcing"`
+	FieldExtraction bool `json:"field_extraction"`
+	EventParsing    bool `json:"event_parsing"`
+	AsyncEvents     bool `json:"async_events"`
+	//
+	// EventSourceID and EventSource are the ID and the name of the event
+	// source of the plugin, available only with EventSourcing
+	EventSourceID uint32 `json:"event_source_id,omitempty"`
+	EventSource   string `json:"event_source,omitempty"`
+}
+
+// PluginOpenParam is an open param suggested by a plugin
+type PluginOpenParam struct {
+	Value string `json:"value"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// PluginInfo is the information about a plugin loaded by Falco
+type PluginInfo struct {
+	Name         string             `json:"name"`
+	Description  string             `json:"description"`
+	Contact      string             `json:"contact"`
+	Version      string             `json:"version"`
+	Capabilities PluginCapabilities `json:"capabilities"`
+	//
+	// InitSchemaType and InitSchema are the type and the content of the
+	// schema of the init config of the plugin, and are empty if the plugin
+	// has no schema. OpenParams are the open params suggested by the
+	// plugin. These are available only with the `--plugin-info` option.
+	InitSchemaType string            `json:"init_schema_type,omitempty"`
+	InitSchema     string            `json:"init_schema,omitempty"`
+	OpenParams     []PluginOpenParam `json:"open_params,omitempty"`
+}
+
+// FieldInfo is a field supported by Falco or by one of its plugins
+type FieldInfo struct {
+	Name string `json:"name"`
+	Desc string `json:"desc,omitempty"`
+}
+
+// FieldClass is a class of fields supported by Falco, which is named
+// after the plugin defining them for the fields of plugins
+type FieldClass struct {
+	Name      string `json:"name"`
+	ShortDesc string `json:"short_desc,omitempty"`
+	//
+	// EventSources are the event sources in which the fields are available
+	EventSources []string    `json:"event_sources,omitempty"`
+	Fields       []FieldInfo `json:"fields"`
+}
+
+// Version converts the output of the Falco run into the versions of Falco
+// and of its components. This is achieved with the Falco `--version` option,
+// either with or without the JSON output enabled. Without the JSON output,
+// only the versions printed by Falco are available. Returns nil if Falco
+// wasn't run for printing its version.
+func (t *TestOutput) Version() *Versions {
+	var res *Versions
+	var err error
+	if t.hasOutputJSON() {
+		res, err = ParseVersions([]byte(t.Stdout()))
+	} else {
+		res, err = parseVersionsText(t.Stdout())
+	}
+	if err != nil {
+		logrus.WithField("stdout", t.Stdout()).WithError(err).Errorf("TestOutput.Version: can't parse stdout")
+		return nil
+	}
+	return res
+}
+
+// PluginList converts the output of the Falco run into the list of the
+// loaded plugins. This is achieved with the Falco `--list-plugins` option,
+// which has no JSON output. Returns nil if Falco wasn't run for listing
+// plugins.
+func (t *TestOutput) PluginList() []*PluginInfo {
+	res, err := parsePluginsText(t.Stdout())
+	if err != nil {
+		logrus.WithField("stdout", t.Stdout()).WithError(err).Errorf("TestOutput.PluginList: can't parse stdout")
+		return nil
+	}
+	return res
+}
+
+// PluginInfo converts the output of the Falco run into the information
+// about a plugin. This is achieved with the Falco `--plugin-info` option,
+// which has no JSON output. Returns nil if Falco wasn't run for printing
+// plugin info. The fields supported by the plugin are not printed by this
+// option, and are available through PluginFields instead.
+func (t *TestOutput) PluginInfo() *PluginInfo {
+	res, err := parsePluginsText(t.Stdout())
+	if err == nil && len(res) != 1 {
+		err = fmt.Errorf("expected info about one plugin, found %d", len(res))
+	}
+	if err != nil {
+		logrus.WithField("stdout", t.Stdout()).WithError(err).Errorf("TestOutput.PluginInfo: can't parse stdout")
+		return nil
+	}
+	return res[0]
+}
+
+// FieldClasses converts the output of the Falco run into the classes of
+// fields supported by Falco and by its plugins. This is achieved with the
+// Falco `--list` option, optionally restricted to a given event source
+// (e.g. `--list=aws_cloudtrail`). Returns nil if Falco wasn't run for
+// listing fields.
+func (t *TestOutput) FieldClasses() []*FieldClass {
+	res := parseFieldClassesText(t.Stdout())
+	if len(res) == 0 {
+		logrus.WithField("stdout", t.Stdout()).Errorf("TestOutput.FieldClasses: no field class found in stdout")
+		return nil
+	}
+	return res
+}
+
+// PluginFields converts the output of the Falco run into the fields
+// supported by the plugin with the given name, which are the ones of its
+// field class (see FieldClasses). Returns nil if the fields of the plugin
+// are not listed.
+func (t *TestOutput) PluginFields(plugin string) []FieldInfo {
+	for _, c := range t.FieldClasses() {
+		if c.Name == plugin {
+			return c.Fields
+		}
+	}
+	return nil
+}
+
+// splitKeyValue splits a "key: value" line
+func splitKeyValue(line string) (string, string, bool) {
+	key, value, ok := strings.Cut(line, ":")
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+func parseVersionsText(text string) (*Versions, error) {
+	res := &Versions{}
+	for _, line := range strings.Split(text, "\n") {
+		key, value, ok := splitKeyValue(line)
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Falco version":
+			res.FalcoVersion = versionArchRegex.ReplaceAllString(value, "")
+		case "Libs version":
+			res.LibsVersion = value
+		case "Plugin API":
+			res.PluginAPIVersion = value
+		case "Engine":
+			// note: recent Falco versions print the engine version as semver
+			if strings.Contains(value, ".") {
+				res.EngineVersionSemver = value
+			} else {
+				res.EngineVersion = value
+			}
+		case "API version":
+			res.DriverAPIVersion = value
+		case "Schema version":
+			res.DriverSchemaVersion = value
+		case "Default driver":
+			res.DefaultDriverVersion = value
+		}
+	}
+	if len(res.FalcoVersion) == 0 {
+		return nil, fmt.Errorf("falco version not found")
+	}
+	return res, nil
+}
+
+// pluginsTextSection is a section of the text output of the
+// info about a plugin which spans multiple lines
+type pluginsTextSection int
+
+const (
+	pluginsTextNone pluginsTextSection = iota
+	pluginsTextCapabilities
+	pluginsTextInitSchema
+	pluginsTextOpenParams
+)
+
+func parsePluginsText(text string) ([]*PluginInfo, error) {
+	var res []*PluginInfo
+	var cur *PluginInfo
+	var schema []string
+	section := pluginsTextNone
+	flushSchema := func() {
+		if cur != nil && len(schema) > 0 {
+			cur.InitSchema = strings.TrimSpace(strings.Join(schema, "\n"))
+		}
+		schema = nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		key, value, _ := splitKeyValue(trimmed)
+		if key == "Name" {
+			flushSchema()
+			cur = &PluginInfo{Name: value}
+			res = append(res, cur)
+			section = pluginsTextNone
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(trimmed, "Init config schema type:"):
+			section = pluginsTextInitSchema
+			if !strings.HasPrefix(value, "Not available") {
+				cur.InitSchemaType = value
+			}
+			continue
+		case strings.HasPrefix(trimmed, "Suggested open params:"):
+			flushSchema()
+			section = pluginsTextOpenParams
+			continue
+		case strings.HasPrefix(trimmed, "No suggested open params available"):
+			flushSchema()
+			section = pluginsTextNone
+			continue
+		}
+
+		switch section {
+		case pluginsTextCapabilities:
+			if strings.HasPrefix(trimmed, "- ") {
+				if err := parsePluginCapability(&cur.Capabilities, strings.TrimPrefix(trimmed, "- ")); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if len(trimmed) == 0 {
+				continue
+			}
+			section = pluginsTextNone
+		case pluginsTextInitSchema:
+			if cur.InitSchemaType != "" {
+				schema = append(schema, line)
+			}
+			continue
+		case pluginsTextOpenParams:
+			if len(trimmed) > 0 {
+				value, desc, _ := strings.Cut(trimmed, ": ")
+				cur.OpenParams = append(cur.OpenParams, PluginOpenParam{Value: value, Desc: desc})
+			}
+			continue
+		}
+
+		switch key {
+		case "Description":
+			cur.Description = value
+		case "Contact":
+			cur.Contact = value
+		case "Version":
+			cur.Version = value
+		case "Capabilities":
+			section = pluginsTextCapabilities
+		}
+	}
+	flushSchema()
+	return res, nil
+}
+
+func parsePluginCapability(caps *PluginCapabilities, line string) error {
+	switch {
+	case strings.HasPrefix(line, "Event Sourcing"):
+		caps.EventSourcing = true
+		if m := eventSourcingRegex.FindStringSubmatch(line); m != nil {
+			id, err := strconv.ParseUint(m[1], 10, 32)
+			if err != nil {
+				return err
+			}
+			caps.EventSourceID = uint32(id)
+			caps.EventSource = m[2]
+		}
+	case strings.HasPrefix(line, "Field Extraction"):
+		caps.FieldExtraction = true
+	case strings.HasPrefix(line, "Event Parsing"):
+		caps.EventParsing = true
+	case strings.HasPrefix(line, "Async Events"):
+		caps.AsyncEvents = true
+	default:
+		logrus.WithField("capability", line).Debug("unknown plugin capability")
+	}
+	return nil
+}
+
+func parseFieldClassesText(text string) []*FieldClass {
+	var res []*FieldClass
+	var cur *FieldClass
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		key, value, _ := splitKeyValue(trimmed)
+		switch {
+		case key == "Field Class":
+			cur = &FieldClass{Name: value}
+			if m := fieldClassRegex.FindStringSubmatch(value); m != nil {
+				cur.Name, cur.ShortDesc = m[1], m[2]
+			}
+			res = append(res, cur)
+		case cur == nil || len(trimmed) == 0:
+		case key == "Event Sources" && len(cur.Fields) == 0:
+			for _, src := range strings.Split(value, ",") {
+				cur.EventSources = append(cur.EventSources, strings.TrimSpace(src))
+			}
+		case line != trimmed:
+			// note: long descriptions continue on indented lines
+			if n := len(cur.Fields); n > 0 {
+				cur.Fields[n-1].Desc = strings.TrimSpace(cur.Fields[n-1].Desc + " " + trimmed)
+			}
+		default:
+			// note: lines not starting with a field name are the
+			// description of the field class
+			if m := fieldInfoRegex.FindStringSubmatch(line); m != nil {
+				cur.Fields = append(cur.Fields, FieldInfo{Name: m[1], Desc: strings.TrimSpace(m[2])})
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVersionText = `Falco version: 0.37.0 (x86_64)
+Libs version:  0.14.1
+Plugin API:    3.2.0
+Engine:        0.31.0
+Driver:
+  API version:    8.0.0
+  Schema version: 2.0.0
+  Default driver: 7.0.0+driver
+`
+
+const testListPluginsText = `2 Plugins Loaded:
+
+Name: cloudtrail
+Description: Reads Cloudtrail JSON logs from files/S3 and injects as events
+Contact: github.com/falcosecurity/plugins
+Version: 0.9.0
+Capabilities:
+  - Event Sourcing (ID=2, source='aws_cloudtrail')
+  - Field Extraction
+
+Name: json
+Description: Extract values from any JSON payload
+Contact: github.com/falcosecurity/plugins
+Version: 0.7.1
+Capabilities:
+
+  - Field Extraction
+`
+
+const testPluginInfoText = `Name: cloudtrail
+Description: Reads Cloudtrail JSON logs from files/S3 and injects as events
+Contact: github.com/falcosecurity/plugins
+Version: 0.9.0
+Capabilities:
+  - Event Sourcing (ID=2, source='aws_cloudtrail')
+  - Field Extraction
+
+Init config schema type: JSON
+{"$schema":"http://json-schema.org/draft-04/schema#","type":"object"}
+
+Suggested open params:
+s3://bucket/prefix: Read all the files in a S3 bucket
+sqs://queue
+`
+
+const testListFieldsText = `
+
+Field Class:                  cloudtrail (Fields for CloudTrail events)
+Event Sources:                aws_cloudtrail
+
+ct.id                         the unique ID of the cloudtrail event.
+ct.error                      The error code from the event. Will be "<NA>" (e.g. the NULL/empty/none
+                              value) if there was no error.
+ct.info
+
+Field Class:                  json
+Event Sources:                k8s_audit, aws_cloudtrail
+
+Fields extracted from any JSON payload.
+
+json.value[/x/y]              Extracts a value from a JSON-encoded input.
+`
+
+func testCommandOutput(stdout string, json bool) *TestOutput {
+	res := &TestOutput{opts: &testOptions{}}
+	if json {
+		WithOutputJSON()(res.opts)
+	}
+	res.stdout.WriteString(stdout)
+	return res
+}
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+		v := testCommandOutput(testVersionText, false).Version()
+		require.NotNil(t, v)
+		assert.Equal(t, &Versions{
+			FalcoVersion:         "0.37.0",
+			LibsVersion:          "0.14.1",
+			PluginAPIVersion:     "3.2.0",
+			EngineVersionSemver:  "0.31.0",
+			DriverAPIVersion:     "8.0.0",
+			DriverSchemaVersion:  "2.0.0",
+			DefaultDriverVersion: "7.0.0+driver",
+		}, v)
+	})
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		v := testCommandOutput(testVersions, true).Version()
+		require.NotNil(t, v)
+		assert.Equal(t, "0.37.0", v.FalcoVersion)
+		assert.Equal(t, "31", v.EngineVersion)
+		assert.Equal(t, "0.31.0", v.EngineVersionSemver)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, testCommandOutput("some text", false).Version())
+		assert.Nil(t, testCommandOutput("some text", true).Version())
+	})
+}
+
+func TestPluginList(t *testing.T) {
+	t.Parallel()
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+		plugins := testCommandOutput(testListPluginsText, false).PluginList()
+		require.Len(t, plugins, 2)
+		assert.Equal(t, &PluginInfo{
+			Name:        "cloudtrail",
+			Description: "Reads Cloudtrail JSON logs from files/S3 and injects as events",
+			Contact:     "github.com/falcosecurity/plugins",
+			Version:     "0.9.0",
+			Capabilities: PluginCapabilities{
+				EventSourcing:   true,
+				FieldExtraction: true,
+				EventSourceID:   2,
+				EventSource:     "aws_cloudtrail",
+			},
+		}, plugins[0])
+		assert.Equal(t, "json", plugins[1].Name)
+		assert.Equal(t, "0.7.1", plugins[1].Version)
+		assert.Equal(t, PluginCapabilities{FieldExtraction: true}, plugins[1].Capabilities)
+	})
+}
+
+func TestPluginInfo(t *testing.T) {
+	t.Parallel()
+	t.Run("text", func(t *testing.T) {
+		t.Parallel()
+		info := testCommandOutput(testPluginInfoText, false).PluginInfo()
+		require.NotNil(t, info)
+		assert.Equal(t, "cloudtrail", info.Name)
+		assert.Equal(t, "0.9.0", info.Version)
+		assert.True(t, info.Capabilities.EventSourcing)
+		assert.Equal(t, "aws_cloudtrail", info.Capabilities.EventSource)
+		assert.Equal(t, "JSON", info.InitSchemaType)
+		assert.Equal(t, `{"$schema":"http://json-schema.org/draft-04/schema#","type":"object"}`, info.InitSchema)
+		assert.Equal(t, []PluginOpenParam{
+			{Value: "s3://bucket/prefix", Desc: "Read all the files in a S3 bucket"},
+			{Value: "sqs://queue"},
+		}, info.OpenParams)
+	})
+	t.Run("no-schema", func(t *testing.T) {
+		t.Parallel()
+		info := testCommandOutput(`Name: json
+Version: 0.7.1
+Capabilities:
+  - Field Extraction
+
+Init config schema type: Not available, plugin does not implement the init config schema functionality
+
+`, false).PluginInfo()
+		require.NotNil(t, info)
+		assert.Empty(t, info.InitSchemaType)
+		assert.Empty(t, info.InitSchema)
+		assert.Empty(t, info.OpenParams)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		assert.Nil(t, testCommandOutput(testListPluginsText, false).PluginInfo())
+		assert.Nil(t, testCommandOutput("", false).PluginInfo())
+	})
+}
+
+func TestFieldClasses(t *testing.T) {
+	t.Parallel()
+	out := testCommandOutput(testListFieldsText, false)
+	classes := out.FieldClasses()
+	require.Len(t, classes, 2)
+	assert.Equal(t, &FieldClass{
+		Name:         "cloudtrail",
+		ShortDesc:    "Fields for CloudTrail events",
+		EventSources: []string{"aws_cloudtrail"},
+		Fields: []FieldInfo{
+			{Name: "ct.id", Desc: "the unique ID of the cloudtrail event."},
+			{Name: "ct.error", Desc: `The error code from the event. Will be "<NA>" (e.g. the NULL/empty/none value) if there was no error.`},
+			{Name: "ct.info"},
+		},
+	}, classes[0])
+	assert.Equal(t, &FieldClass{
+		Name:         "json",
+		EventSources: []string{"k8s_audit", "aws_cloudtrail"},
+		Fields:       []FieldInfo{{Name: "json.value[/x/y]", Desc: "Extracts a value from a JSON-encoded input."}},
+	}, classes[1])
+	assert.Equal(t, classes[1].Fields, out.PluginFields("json"))
+	assert.Nil(t, out.PluginFields("k8saudit"))
+	assert.Nil(t, testCommandOutput(testPluginInfoText, false).FieldClasses())
+}
@@ -19,14 +19,27 @@ limitations under the License.
 package falco
 
 import (
-	"encoding/json"
+	"fmt"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
-
-	"github.com/sirupsen/logrus"
 )
 
+// priorities is the list of Falco priorities sorted from the most
+// to the least severe one
+var priorities = []string{
+	"emergency",
+	"alert",
+	"critical",
+	"error",
+	"warning",
+	"notice",
+	"informational",
+	"debug",
+}
+
 // Alert represent an alert produced by a Falco rule.
 type Alert struct {
 	Time         time.Time              `json:"time"`
@@ -43,27 +56,12 @@ type Alert struct {
 type Detections []*Alert
 
 // Detections converts the output of the Falco run into a list of rule detections.
+// Alerts are parsed both in JSON and text formats. In text format, only the
+// time, priority, and output of the alerts are available, unless a custom format
+// is specified with WithTextOutputFormat.
 // Returns nil if Falco wasn't run for rules detection.
 func (t *TestOutput) Detections() Detections {
-	if !t.hasOutputJSON() {
-		logrus.Errorf("TestOutput.Detections: must use WithOutputJSON")
-	}
-
-	lines, err := readLineByLine(strings.NewReader(t.Stdout()))
-	if err != nil {
-		logrus.WithError(err).Errorf("TestOutput.Detections: can't read stdout line by line")
-		return nil
-	}
-	var res Detections
-	for _, line := range lines {
-		alert := Alert{}
-		if err := json.Unmarshal([]byte(line), &alert); err != nil {
-			logrus.WithField("line", line).Tracef("TestOutput.Detections: stdout line not JSON")
-			continue
-		}
-		res = append(res, &alert)
-	}
-	return res
+	return ParseDetections(t.Stdout(), t.opts.textOutputFormats...)
 }
 
 func (d Detections) filter(f func(*Alert) bool) Detections {
@@ -87,6 +85,9 @@ func (d Detections) OfPriority(p string) Detections {
 
 // OfRule returns the list of detections that have a given rule name.
 // The rule name can either be a string or a *regexp.Regexp.
+// Alerts parsed from the default text output of Falco have no rule name
+// and never match, so either the JSON output or a custom text format
+// with a "rule" group (see WithTextOutputFormat) must be used.
 func (d Detections) OfRule(v interface{}) Detections {
 	return d.filter(func(a *Alert) bool {
 		if rgx, ok := v.(*regexp.Regexp); ok {
@@ -99,7 +100,171 @@ func (d Detections) OfRule(v interface{}) Detections {
 	})
 }
 
+// OfTag returns the list of detections of rules having a given tag.
+func (d Detections) OfTag(tag string) Detections {
+	return d.filter(func(a *Alert) bool {
+		for _, t := range a.Tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// OfSource returns the list of detections that have a given event source.
+func (d Detections) OfSource(source string) Detections {
+	return d.filter(func(a *Alert) bool {
+		return a.Source == source
+	})
+}
+
+// OfHostname returns the list of detections that have a given hostname.
+func (d Detections) OfHostname(hostname string) Detections {
+	return d.filter(func(a *Alert) bool {
+		return a.Hostname == hostname
+	})
+}
+
+// Between returns the list of detections that happened in the given
+// time window, including its boundaries.
+func (d Detections) Between(from, to time.Time) Detections {
+	return d.filter(func(a *Alert) bool {
+		return !a.Time.Before(from) && !a.Time.After(to)
+	})
+}
+
+// WithOutputField returns the list of detections having an output field
+// with a given key and value. The value can either be a string or a
+// *regexp.Regexp, and is compared with the string representation of the
+// output field's value.
+func (d Detections) WithOutputField(key string, v interface{}) Detections {
+	return d.filter(func(a *Alert) bool {
+		value, ok := a.OutputFields[key]
+		if !ok {
+			return false
+		}
+		if rgx, ok := v.(*regexp.Regexp); ok {
+			return rgx.MatchString(outputFieldString(value))
+		}
+		if str, ok := v.(string); ok {
+			return outputFieldString(value) == str
+		}
+		panic("argument must be string or *regexp.Regexp")
+	})
+}
+
+// AtLeast returns the list of detections that have a priority equal or
+// more severe than the given one (e.g. AtLeast("WARNING") includes "ERROR").
+func (d Detections) AtLeast(p string) Detections {
+	level := mustPriorityLevel(p)
+	return d.filter(func(a *Alert) bool {
+		l, ok := priorityLevel(a.Priority)
+		return ok && l <= level
+	})
+}
+
+// AtMost returns the list of detections that have a priority equal or
+// less severe than the given one (e.g. AtMost("WARNING") includes "NOTICE").
+func (d Detections) AtMost(p string) Detections {
+	level := mustPriorityLevel(p)
+	return d.filter(func(a *Alert) bool {
+		l, ok := priorityLevel(a.Priority)
+		return ok && l >= level
+	})
+}
+
+// GroupByRule returns the detections grouped by their rule name.
+func (d Detections) GroupByRule() map[string]Detections {
+	res := make(map[string]Detections)
+	for _, a := range d {
+		res[a.Rule] = append(res[a.Rule], a)
+	}
+	return res
+}
+
+// First returns the first alert in the list of detections,
+// or nil if the list is empty.
+func (d Detections) First() *Alert {
+	if len(d) == 0 {
+		return nil
+	}
+	return d[0]
+}
+
+// Last returns the last alert in the list of detections,
+// or nil if the list is empty.
+func (d Detections) Last() *Alert {
+	if len(d) == 0 {
+		return nil
+	}
+	return d[len(d)-1]
+}
+
+// SortBy returns a copy of the list of detections sorted with the given
+// less function. The sort is stable.
+func (d Detections) SortBy(less func(a, b *Alert) bool) Detections {
+	res := make(Detections, len(d))
+	copy(res, d)
+	sort.SliceStable(res, func(i, j int) bool { return less(res[i], res[j]) })
+	return res
+}
+
+// SortByTime returns a copy of the list of detections sorted by time,
+// from the oldest to the newest alert.
+func (d Detections) SortByTime() Detections {
+	return d.SortBy(func(a, b *Alert) bool { return a.Time.Before(b.Time) })
+}
+
+// SortByPriority returns a copy of the list of detections sorted by
+// priority, from the most to the least severe alert.
+func (d Detections) SortByPriority() Detections {
+	return d.SortBy(func(a, b *Alert) bool {
+		la, _ := priorityLevel(a.Priority)
+		lb, _ := priorityLevel(b.Priority)
+		return la < lb
+	})
+}
+
 // Count returns the amount of alerts in the list of detections.
 func (d Detections) Count() int {
 	return len(d)
 }
+
+// priorityLevel returns the severity level of a priority, where lower
+// levels are more severe. Returns false if the priority is unknown.
+func priorityLevel(p string) (int, bool) {
+	p = strings.ToLower(p)
+	// note: we need to support "INFO" because of the
+	// INFO -> INFORMATIONAL changes we had in the past
+	if p == "info" {
+		p = "informational"
+	}
+	for i, name := range priorities {
+		if name == p {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func mustPriorityLevel(p string) int {
+	level, ok := priorityLevel(p)
+	if !ok {
+		panic(fmt.Sprintf("unknown priority '%s'", p))
+	}
+	return level
+}
+
+func outputFieldString(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "<NA>"
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectionsQueries(t *testing.T) {
+	t0 := time.Date(2016, 8, 4, 16, 17, 57, 0, time.UTC)
+	d := Detections{
+		{Time: t0.Add(2 * time.Second), Rule: "r1", Priority: "Warning", Source: "syscall", Hostname: "h1", Tags: []string{"a", "b"},
+			OutputFields: map[string]interface{}{"proc.name": "cat", "proc.pid": float64(1234)}},
+		{Time: t0, Rule: "r2", Priority: "Critical", Source: "k8s_audit", Hostname: "h2", Tags: []string{"b"},
+			OutputFields: map[string]interface{}{"proc.name": "bash"}},
+		{Time: t0.Add(time.Second), Rule: "r1", Priority: "Informational", Source: "syscall", Hostname: "h1"},
+	}
+
+	require.Equal(t, 1, d.OfTag("a").Count())
+	require.Equal(t, 2, d.OfTag("b").Count())
+	require.Equal(t, 2, d.OfSource("syscall").Count())
+	require.Equal(t, 1, d.OfHostname("h2").Count())
+	require.Equal(t, 2, d.Between(t0, t0.Add(time.Second)).Count())
+	require.Equal(t, 1, d.WithOutputField("proc.name", "cat").Count())
+	require.Equal(t, 1, d.WithOutputField("proc.pid", "1234").Count())
+	require.Equal(t, 2, d.WithOutputField("proc.name", regexp.MustCompile(`^(cat|bash)$`)).Count())
+	require.Equal(t, 2, d.AtLeast("WARNING").Count())
+	require.Equal(t, 3, d.AtLeast("INFO").Count())
+	require.Equal(t, 2, d.AtMost("warning").Count())
+	require.Panics(t, func() { d.AtLeast("not-a-priority") })
+	// ambiguous or partial priority names are not accepted
+	require.Panics(t, func() { d.AtLeast("e") })
+	require.Panics(t, func() { d.AtMost("warn") })
+
+	groups := d.GroupByRule()
+	require.Len(t, groups, 2)
+	require.Equal(t, 2, groups["r1"].Count())
+
+	require.Equal(t, "r1", d.First().Rule)
+	require.Equal(t, "Informational", d.Last().Priority)
+	require.Nil(t, Detections{}.First())
+	require.Nil(t, Detections{}.Last())
+
+	sorted := d.SortByTime()
+	require.Equal(t, "r2", sorted.First().Rule)
+	require.Equal(t, "Warning", sorted.Last().Priority)
+	require.Equal(t, "r1", d.First().Rule)
+	require.Equal(t, "Informational", d.SortByPriority().Last().Priority)
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMetricsScrapeInterval is the default interval with which
+	// the Prometheus metrics of a running Falco are scraped
+	DefaultMetricsScrapeInterval = time.Second
+	//
+	// MetricsPath is the path of the Prometheus metrics
+	// endpoint of the webserver of Falco
+	MetricsPath = "/metrics"
+)
+
+// MetricsURL returns the URL of the Prometheus metrics endpoint of Falco.
+// Returns an empty string if Falco wasn't run with WithPrometheusMetrics,
+// or if it could not be started.
+func (t *TestOutput) MetricsURL() string {
+	if !t.opts.metrics || len(t.WebserverURL()) == 0 {
+		return ""
+	}
+	return t.WebserverURL() + MetricsPath
+}
+
+// Metrics returns the Prometheus metrics periodically scraped from Falco
+// during the run, ordered by scrape time. Returns nil if Falco wasn't run
+// with WithPrometheusMetrics, or if no scrape succeeded.
+func (t *TestOutput) Metrics() MetricsScrapes {
+	return t.metrics
+}
+
+// scrapeMetrics periodically scrapes the Prometheus metrics
+// of Falco until it exits or until the context is done
+func (p *TestProcess) scrapeMetrics(ctx context.Context, interval time.Duration) {
+	client := p.Webserver()
+	if client == nil {
+		return
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// note: each scrape can last up to the interval
+			scrapeCtx, cancel := context.WithTimeout(ctx, interval)
+			m, err := client.Metrics(scrapeCtx)
+			cancel()
+			if err != nil {
+				// note: scrapes fail until the webserver of Falco is up
+				logrus.WithError(err).Debug("can't scrape falco metrics")
+			} else {
+				p.output.metrics = append(p.output.metrics, m)
+			}
+			select {
+			case <-ticker.C:
+			case <-p.Exited():
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
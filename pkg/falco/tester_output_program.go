@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ProgramOutput is what the program of the `program_output`
+// output channel received during a Falco run.
+type ProgramOutput struct {
+	// Spawns is the number of times Falco spawned the program
+	Spawns int
+	//
+	// Lines are all the lines received by the program through its
+	// standard input, in order
+	Lines []string
+}
+
+// Detections converts the lines received by the program into a list
+// of Falco alerts. Alerts are parsed both in JSON and text formats.
+func (p *ProgramOutput) Detections() Detections {
+	return ParseDetections(strings.Join(p.Lines, "\n"))
+}
+
+// ProgramOutput returns what the program of the `program_output` output
+// channel received during the run. Returns nil if Falco wasn't run with
+// WithProgramOutput.
+func (t *TestOutput) ProgramOutput() *ProgramOutput {
+	if len(t.opts.programOutput) == 0 {
+		return nil
+	}
+	// note: the record file does not exist if the program was never spawned
+	content, err := t.File(t.opts.programOutput)
+	if err != nil {
+		logrus.WithError(err).Debug("can't read program output record")
+		return &ProgramOutput{}
+	}
+	return parseProgramOutputRecord(string(content))
+}
+
+func parseProgramOutputRecord(record string) *ProgramOutput {
+	res := &ProgramOutput{}
+	for _, l := range strings.Split(record, "\n") {
+		switch {
+		case strings.HasPrefix(l, "spawn "):
+			res.Spawns++
+		case strings.HasPrefix(l, "line "):
+			res.Lines = append(res.Lines, strings.TrimPrefix(l, "line "))
+		}
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgramOutputRecorder(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	script := filepath.Join(dir, programOutputScriptFile)
+	require.NoError(t, os.WriteFile(script, []byte(programOutputRecorder), 0644))
+
+	// simulate a spawn with two alerts, and one with a single alert
+	// without a trailing newline
+	alert := `{"rule":"some_rule","priority":"Warning","output":"some output"}`
+	for _, input := range []string{alert + "\n" + alert + "\n", alert} {
+		cmd := exec.Command("/bin/sh", script)
+		cmd.Stdin = strings.NewReader(input)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	record, err := os.ReadFile(filepath.Join(dir, programOutputRecordFile))
+	require.NoError(t, err)
+	res := parseProgramOutputRecord(string(record))
+	assert.Equal(t, 2, res.Spawns)
+	assert.Equal(t, []string{alert, alert, alert}, res.Lines)
+	assert.Equal(t, 3, res.Detections().OfRule("some_rule").OfPriority("WARNING").Count())
+}
+
+func TestProgramOutput_NotEnabled(t *testing.T) {
+	t.Parallel()
+	res := &TestOutput{opts: &testOptions{}}
+	assert.Nil(t, res.ProgramOutput())
+
+	// the record is empty if the program was never spawned
+	opts := &testOptions{}
+	WithProgramOutput(true)(opts)
+	res = &TestOutput{opts: opts}
+	require.NotNil(t, res.ProgramOutput())
+	assert.Equal(t, 0, res.ProgramOutput().Spawns)
+	assert.Contains(t, opts.args, "program_output.keep_alive=true")
+}
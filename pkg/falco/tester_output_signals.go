@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"regexp"
+)
+
+var (
+	restartRegex         = regexp.MustCompile(`SIGHUP received, restarting`)
+	reopenedOutputsRegex = regexp.MustCompile(`SIGUSR1 received, reopening outputs`)
+	shutdownRegex        = regexp.MustCompile(`(SIGINT|SIGTERM) received, exiting`)
+)
+
+// Restarts returns the number of times Falco restarted during its run,
+// either after receiving a SIGHUP or after a change in the watched config files.
+func (t *TestOutput) Restarts() int {
+	return len(restartRegex.FindAllStringIndex(t.Stderr(), -1))
+}
+
+// ReopenedOutputs returns the number of times Falco reopened its outputs
+// during its run after receiving a SIGUSR1.
+func (t *TestOutput) ReopenedOutputs() int {
+	return len(reopenedOutputsRegex.FindAllStringIndex(t.Stderr(), -1))
+}
+
+// GracefulShutdown returns true if Falco exited successfully after receiving
+// a SIGINT or a SIGTERM.
+func (t *TestOutput) GracefulShutdown() bool {
+	return shutdownRegex.MatchString(t.Stderr()) && t.Err() == nil
+}
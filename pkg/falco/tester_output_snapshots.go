@@ -0,0 +1,271 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// MetricsSnapshotRule is the name of the rule of the internal alerts
+	// periodically emitted by Falco with `metrics.output_rule` enabled
+	MetricsSnapshotRule = "Falco internal: metrics snapshot"
+	//
+	// metricsRulePrefix is the prefix of the output fields
+	// containing the per-rule match counters
+	metricsRulePrefix = "falco.rules."
+)
+
+// MetricsSnapshot is a snapshot of the internal metrics of Falco, decoded
+// from the output fields of an alert of the MetricsSnapshotRule rule.
+// Values are reported in the units used by Falco, and are zero if
+// not available.
+type MetricsSnapshot struct {
+	Time          time.Time
+	Source        string
+	Hostname      string
+	Version       string
+	KernelRelease string
+	//
+	// CPUUsagePerc is the CPU usage of Falco in percentage
+	CPUUsagePerc float64
+	//
+	// MemoryRSS, MemoryVSZ, and MemoryPSS are the memory usage of Falco
+	MemoryRSS float64
+	MemoryVSZ float64
+	MemoryPSS float64
+	//
+	// Duration is the time elapsed since Falco started
+	Duration time.Duration
+	//
+	// NumEvents and EventsRate are the number of events processed by
+	// Falco, and their rate per second since the last snapshot
+	NumEvents  uint64
+	EventsRate float64
+	//
+	// OutputsQueueDrops is the number of alerts dropped because the
+	// outputs queue was full
+	OutputsQueueDrops uint64
+	//
+	// ScapEvents, ScapDrops, ScapDropsPerc, and ScapEventsRate are the
+	// number of events captured by the driver, the number and percentage
+	// of dropped events, and the rate of captured events per second
+	ScapEvents     uint64
+	ScapDrops      uint64
+	ScapDropsPerc  float64
+	ScapEventsRate float64
+	//
+	// RuleMatchesTotal is the number of matches of all the rules, and
+	// RuleMatches is the number of matches of each rule
+	RuleMatchesTotal uint64
+	RuleMatches      map[string]uint64
+	//
+	// Fields are all the output fields of the snapshot
+	Fields map[string]interface{}
+}
+
+// MetricsSnapshots is a time series of Falco internal metrics snapshots.
+type MetricsSnapshots []*MetricsSnapshot
+
+// MetricsSnapshots returns the internal metrics snapshots emitted by Falco
+// as alerts during the run, ordered as emitted. Falco must be run with
+// the metrics output rule enabled (e.g. with WithPrometheusMetrics) and
+// with the output in JSON format, because the metrics are reported
+// through the output fields of the alerts.
+func (t *TestOutput) MetricsSnapshots() MetricsSnapshots {
+	return NewMetricsSnapshots(t.Detections())
+}
+
+// NewMetricsSnapshots decodes the internal metrics snapshots
+// from the alerts of the MetricsSnapshotRule rule.
+func NewMetricsSnapshots(d Detections) MetricsSnapshots {
+	var res MetricsSnapshots
+	for _, a := range d.OfRule(MetricsSnapshotRule) {
+		res = append(res, newMetricsSnapshot(a))
+	}
+	return res
+}
+
+func newMetricsSnapshot(a *Alert) *MetricsSnapshot {
+	f := a.OutputFields
+	res := &MetricsSnapshot{
+		Time:              a.Time,
+		Source:            stringField(f, "evt.source"),
+		Hostname:          stringField(f, "falco.hostname"),
+		Version:           stringField(f, "falco.version"),
+		KernelRelease:     stringField(f, "falco.kernel_release"),
+		CPUUsagePerc:      floatField(f, "falco.cpu_usage_perc"),
+		MemoryRSS:         floatField(f, "falco.memory_rss"),
+		MemoryVSZ:         floatField(f, "falco.memory_vsz"),
+		MemoryPSS:         floatField(f, "falco.memory_pss"),
+		Duration:          time.Duration(floatField(f, "falco.duration_sec") * float64(time.Second)),
+		NumEvents:         uint64(floatField(f, "falco.num_evts")),
+		EventsRate:        floatField(f, "falco.evts_rate_sec"),
+		OutputsQueueDrops: uint64(floatField(f, "falco.outputs_queue_num_drops")),
+		ScapEvents:        uint64(floatField(f, "scap.n_evts")),
+		ScapDrops:         uint64(floatField(f, "scap.n_drops")),
+		ScapDropsPerc:     floatField(f, "scap.n_drops_perc"),
+		ScapEventsRate:    floatField(f, "scap.evts_rate_sec"),
+		RuleMatchesTotal:  uint64(floatField(f, metricsRulePrefix+"matches_total")),
+		RuleMatches:       make(map[string]uint64),
+		Fields:            f,
+	}
+	if len(res.Hostname) == 0 {
+		res.Hostname = a.Hostname
+	}
+	if len(res.Source) == 0 {
+		res.Source = a.Source
+	}
+	for k := range f {
+		if name := strings.TrimPrefix(k, metricsRulePrefix); name != k && name != "matches_total" {
+			res.RuleMatches[name] = uint64(floatField(f, k))
+		}
+	}
+	return res
+}
+
+// Field returns the value of an output field of the snapshot as a
+// float64, and false if the field is not available or is not a number.
+func (s *MetricsSnapshot) Field(name string) (float64, bool) {
+	v, ok := s.Fields[name]
+	if !ok {
+		return 0, false
+	}
+	res, err := toFloat(v)
+	return res, err == nil
+}
+
+// OfSource returns the snapshots of a given event source.
+func (s MetricsSnapshots) OfSource(source string) MetricsSnapshots {
+	var res MetricsSnapshots
+	for _, m := range s {
+		if m.Source == source {
+			res = append(res, m)
+		}
+	}
+	return res
+}
+
+// First returns the first snapshot, or nil if there are none.
+func (s MetricsSnapshots) First() *MetricsSnapshot {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
+
+// Last returns the last snapshot, or nil if there are none.
+func (s MetricsSnapshots) Last() *MetricsSnapshot {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[len(s)-1]
+}
+
+// Series returns the values obtained by applying the given function
+// to each snapshot, in order.
+func (s MetricsSnapshots) Series(f func(*MetricsSnapshot) float64) []float64 {
+	res := make([]float64, 0, len(s))
+	for _, m := range s {
+		res = append(res, f(m))
+	}
+	return res
+}
+
+// Max returns the max of the values obtained by applying the given
+// function to each snapshot, or zero if there are no snapshots.
+func (s MetricsSnapshots) Max(f func(*MetricsSnapshot) float64) float64 {
+	res := float64(0)
+	for i, v := range s.Series(f) {
+		if i == 0 || v > res {
+			res = v
+		}
+	}
+	return res
+}
+
+// Min returns the min of the values obtained by applying the given
+// function to each snapshot, or zero if there are no snapshots.
+func (s MetricsSnapshots) Min(f func(*MetricsSnapshot) float64) float64 {
+	res := float64(0)
+	for i, v := range s.Series(f) {
+		if i == 0 || v < res {
+			res = v
+		}
+	}
+	return res
+}
+
+// Avg returns the average of the values obtained by applying the given
+// function to each snapshot, or zero if there are no snapshots.
+func (s MetricsSnapshots) Avg(f func(*MetricsSnapshot) float64) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	res := float64(0)
+	for _, v := range s.Series(f) {
+		res += v
+	}
+	return res / float64(len(s))
+}
+
+// Delta returns the difference between the values obtained by applying
+// the given function to the last and to the first snapshot, or zero
+// if there are no snapshots.
+func (s MetricsSnapshots) Delta(f func(*MetricsSnapshot) float64) float64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return f(s.Last()) - f(s.First())
+}
+
+func stringField(fields map[string]interface{}, key string) string {
+	if v, ok := fields[key]; ok && v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+func floatField(fields map[string]interface{}, key string) float64 {
+	res, _ := toFloat(fields[key])
+	return res
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int64:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case json.Number:
+		return n.Float64()
+	case string:
+		return strconv.ParseFloat(n, 64)
+	default:
+		return 0, fmt.Errorf("value %v is not a number", v)
+	}
+}
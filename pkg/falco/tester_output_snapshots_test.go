@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMetricsSnapshotAlert(i int) string {
+	return fmt.Sprintf(`{"hostname":"host","output":"Falco metrics snapshot","output_fields":{`+
+		`"evt.source":"syscall","evt.time":%d,"falco.cpu_usage_perc":%d.5,"falco.duration_sec":%d,`+
+		`"falco.evts_rate_sec":10.0,"falco.hostname":"host","falco.kernel_release":"6.2.0","falco.memory_rss":%d.0,`+
+		`"falco.memory_vsz":500.0,"falco.num_evts":%d,"falco.outputs_queue_num_drops":0,`+
+		`"falco.rules.matches_total":%d,"falco.rules.open_from_cat":%d,"falco.version":"0.37.0",`+
+		`"scap.n_drops":1,"scap.n_drops_perc":0.5,"scap.n_evts":%d},`+
+		`"priority":"Informational","rule":"Falco internal: metrics snapshot","source":"internal",`+
+		`"time":"2023-01-01T00:00:0%d.000000000Z"}`,
+		i, i, 2*i, 50+i, 100*i, i, i, 200*i, i)
+}
+
+func TestMetricsSnapshots(t *testing.T) {
+	t.Parallel()
+	lines := []string{`{"output":"some output","priority":"Warning","rule":"open_from_cat","source":"syscall","time":"2023-01-01T00:00:00.000000000Z"}`}
+	for i := 1; i <= 3; i++ {
+		lines = append(lines, testMetricsSnapshotAlert(i))
+	}
+	snapshots := NewMetricsSnapshots(ParseDetections(strings.Join(lines, "\n")))
+	require.Len(t, snapshots, 3)
+
+	first := snapshots.First()
+	assert.Equal(t, "syscall", first.Source)
+	assert.Equal(t, "host", first.Hostname)
+	assert.Equal(t, "0.37.0", first.Version)
+	assert.Equal(t, "6.2.0", first.KernelRelease)
+	assert.Equal(t, 1.5, first.CPUUsagePerc)
+	assert.Equal(t, float64(51), first.MemoryRSS)
+	assert.Equal(t, float64(500), first.MemoryVSZ)
+	assert.Equal(t, 2*time.Second, first.Duration)
+	assert.Equal(t, uint64(100), first.NumEvents)
+	assert.Equal(t, float64(10), first.EventsRate)
+	assert.Equal(t, uint64(200), first.ScapEvents)
+	assert.Equal(t, uint64(1), first.ScapDrops)
+	assert.Equal(t, 0.5, first.ScapDropsPerc)
+	assert.Equal(t, uint64(1), first.RuleMatchesTotal)
+	assert.Equal(t, map[string]uint64{"open_from_cat": 1}, first.RuleMatches)
+	v, ok := first.Field("evt.time")
+	assert.True(t, ok)
+	assert.Equal(t, float64(1), v)
+	_, ok = first.Field("falco.version")
+	assert.False(t, ok)
+
+	memory := func(s *MetricsSnapshot) float64 { return s.MemoryRSS }
+	matches := func(s *MetricsSnapshot) float64 { return float64(s.RuleMatches["open_from_cat"]) }
+	assert.Equal(t, []float64{51, 52, 53}, snapshots.Series(memory))
+	assert.Equal(t, float64(53), snapshots.Max(memory))
+	assert.Equal(t, float64(51), snapshots.Min(memory))
+	assert.Equal(t, float64(52), snapshots.Avg(memory))
+	assert.Equal(t, float64(2), snapshots.Delta(matches))
+	assert.Len(t, snapshots.OfSource("syscall"), 3)
+	assert.Empty(t, snapshots.OfSource("k8s_audit"))
+
+	var empty MetricsSnapshots
+	assert.Nil(t, empty.First())
+	assert.Nil(t, empty.Last())
+	assert.Equal(t, float64(0), empty.Max(memory))
+	assert.Equal(t, float64(0), empty.Avg(memory))
+	assert.Equal(t, float64(0), empty.Delta(memory))
+}
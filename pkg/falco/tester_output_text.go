@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const textPriorityRegex = `(?P<priority>Emergency|Alert|Critical|Error|Warning|Notice|Informational|Info|Debug)`
+
+var (
+	// TextOutputFormatISO8601 matches the alerts printed by Falco in text
+	// mode when `time_format_iso_8601` is enabled
+	// (e.g. `2016-08-04T16:17:57.881781397+0000: Warning An open was seen`).
+	// The rule name is not part of the default text output of Falco, so
+	// it's not available in the alerts parsed with this format.
+	TextOutputFormatISO8601 = regexp.MustCompile(
+		`^(?P<time>\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d+[+-]\d{4}): ` + textPriorityRegex + ` .*$`)
+	//
+	// TextOutputFormatDefault matches the alerts printed by Falco in text
+	// mode with the default time format (e.g. `16:17:57.881781397: Warning An open was seen`).
+	// As for TextOutputFormatISO8601, the rule name is not available.
+	TextOutputFormatDefault = regexp.MustCompile(
+		`^(?P<time>\d{2}:\d{2}:\d{2}\.\d+): ` + textPriorityRegex + ` .*$`)
+)
+
+var textOutputTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999-0700",
+	"15:04:05.999999999",
+	time.RFC3339Nano,
+}
+
+// ParseDetections converts a text containing Falco alerts into a list of
+// rule detections, supporting alerts in both JSON and text formats.
+// Alerts in text format are matched with the given regular expressions, or
+// with TextOutputFormatISO8601 and TextOutputFormatDefault if none is passed.
+// See WithTextOutputFormat for details about the supported custom formats.
+// Lines that are not alerts are ignored.
+func ParseDetections(text string, formats ...*regexp.Regexp) Detections {
+	if len(formats) == 0 {
+		formats = []*regexp.Regexp{TextOutputFormatISO8601, TextOutputFormatDefault}
+	}
+	lines, err := readLineByLine(strings.NewReader(text))
+	if err != nil {
+		logrus.WithError(err).Errorf("ParseDetections: can't read text line by line")
+		return nil
+	}
+	var res Detections
+	for _, line := range lines {
+		alert := &Alert{}
+		if err := json.Unmarshal([]byte(line), alert); err == nil {
+			res = append(res, alert)
+			continue
+		}
+		if alert = parseTextAlert(line, formats); alert != nil {
+			res = append(res, alert)
+			continue
+		}
+		logrus.WithField("line", line).Tracef("ParseDetections: line is not an alert")
+	}
+	return res
+}
+
+func parseTextAlert(line string, formats []*regexp.Regexp) *Alert {
+	for _, rgx := range formats {
+		match := rgx.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		res := &Alert{Output: line}
+		for i, name := range rgx.SubexpNames() {
+			switch name {
+			case "time":
+				res.Time = parseTextAlertTime(match[i])
+			case "priority":
+				res.Priority = match[i]
+			case "rule":
+				res.Rule = match[i]
+			case "source":
+				res.Source = match[i]
+			case "hostname":
+				res.Hostname = match[i]
+			case "tags":
+				res.Tags = strings.Split(match[i], ",")
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func parseTextAlertTime(s string) time.Time {
+	for _, layout := range textOutputTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	logrus.WithField("time", s).Debugf("ParseDetections: can't parse alert time")
+	return time.Time{}
+}
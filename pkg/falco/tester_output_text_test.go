@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDetections(t *testing.T) {
+	t.Run("text-iso8601", func(t *testing.T) {
+		res := ParseDetections(`Thu Aug  4 16:17:57 2016: Falco initialized
+2016-08-04T16:17:57.881781397+0000: Warning An open was seen (command=cat /dev/null)
+2016-08-04T16:17:58.000000000+0000: Error Something else happened
+Events detected: 2`)
+		require.Equal(t, 2, res.Count())
+		require.Equal(t, 1, res.OfPriority("WARNING").Count())
+		require.Equal(t, "2016-08-04T16:17:57.881781397+0000: Warning An open was seen (command=cat /dev/null)", res[0].Output)
+		require.Equal(t, time.Date(2016, 8, 4, 16, 17, 57, 881781397, time.UTC), res[0].Time.UTC())
+	})
+	t.Run("text-default", func(t *testing.T) {
+		res := ParseDetections(`16:17:57.881781397: Informational An open was seen`)
+		require.Equal(t, 1, res.Count())
+		require.Equal(t, "Informational", res[0].Priority)
+		require.Equal(t, 16, res[0].Time.Hour())
+		require.Equal(t, 881781397, res[0].Time.Nanosecond())
+		// the rule name is not printed in the default text output
+		require.Empty(t, res[0].Rule)
+		require.Equal(t, 0, res.OfRule("open_from_cat").Count())
+	})
+	t.Run("json", func(t *testing.T) {
+		res := ParseDetections(`{"output":"out","priority":"Warning","rule":"open_from_cat","source":"syscall","time":"2016-08-04T16:17:57.881781397Z"}
+not an alert`)
+		require.Equal(t, 1, res.OfRule("open_from_cat").Count())
+	})
+	t.Run("text-custom", func(t *testing.T) {
+		format := regexp.MustCompile(`^(?P<priority>\w+) \[(?P<rule>[^\]]+)\] (?P<hostname>\S+) .*$`)
+		res := ParseDetections(`Warning [open_from_cat] myhost An open was seen`, format)
+		require.Equal(t, 1, res.Count())
+		require.Equal(t, "open_from_cat", res[0].Rule)
+		require.Equal(t, 1, res.OfRule("open_from_cat").Count())
+		require.Equal(t, "myhost", res[0].Hostname)
+		require.Equal(t, "Warning", res[0].Priority)
+	})
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/falcosecurity/testing/pkg/run"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+)
+
+var errNotRunning = fmt.Errorf("falco process is not running")
+
+// TestProcess is a handle to a Falco test run started asynchronously
+type TestProcess struct {
+	output   *TestOutput
+	process  run.Process
+	cancel   context.CancelFunc
+	exited   chan struct{}
+	startErr error
+	readyErr error
+	waitErr  error
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// Err returns a non-nil error in case of issues when starting Falco,
+// including the case in which Falco did not become ready.
+func (p *TestProcess) Err() error {
+	return multierr.Combine(p.output.opts.err, p.startErr, p.readyErr)
+}
+
+// Exited returns a channel that is closed once the Falco process exits.
+func (p *TestProcess) Exited() <-chan struct{} {
+	if p.exited == nil {
+		res := make(chan struct{})
+		close(res)
+		return res
+	}
+	return p.exited
+}
+
+// watch waits for the Falco process in the background, so that its exit
+// can be observed while it's still running
+func (p *TestProcess) watch() {
+	p.exited = make(chan struct{})
+	go func() {
+		defer close(p.exited)
+		p.waitErr = p.process.Wait()
+	}()
+}
+
+// Signal sends a signal to the running Falco process.
+func (p *TestProcess) Signal(sig os.Signal) error {
+	if p.process == nil {
+		return errNotRunning
+	}
+	logrus.WithField("signal", sig).Debug("sending signal to falco")
+	return p.process.Signal(sig)
+}
+
+// Pid returns the identifier of the running Falco process.
+// Returns 0 if Falco is not running.
+func (p *TestProcess) Pid() int {
+	if p.process == nil {
+		return 0
+	}
+	return p.process.Pid()
+}
+
+// Stdout returns a reader for the stdout of the running Falco process,
+// from which lines are available as soon as Falco produces them.
+func (p *TestProcess) Stdout() io.Reader {
+	if p.process == nil {
+		return strings.NewReader("")
+	}
+	return p.process.Stdout()
+}
+
+// Stderr returns a reader for the stderr of the running Falco process,
+// from which lines are available as soon as Falco produces them.
+func (p *TestProcess) Stderr() io.Reader {
+	if p.process == nil {
+		return strings.NewReader("")
+	}
+	return p.process.Stderr()
+}
+
+// scheduleSignal sends a signal to the running Falco process after a given
+// delay, unless the context gets cancelled first
+func (p *TestProcess) scheduleSignal(ctx context.Context, s scheduledSignal) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		timer := time.NewTimer(s.delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			if err := p.Signal(s.signal); err != nil {
+				logrus.WithError(err).WithField("signal", s.signal).Warn("can't send signal to falco")
+			}
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// Wait waits for the Falco process to finish its execution, and produces
+// an output representing the outcome of the run. Calling Wait more than
+// once returns the same output.
+func (p *TestProcess) Wait() *TestOutput {
+	p.once.Do(func() {
+		<-p.Exited()
+		if p.waitErr != nil {
+			logrus.WithError(p.waitErr).Warn("error running falco with runner")
+		}
+		if p.cancel != nil {
+			p.cancel()
+		}
+		p.wg.Wait()
+		p.closeGRPC()
+		releaseTCPPorts(p.output.opts.tcpPorts...)
+		if p.process != nil {
+			p.output.files = p.process.OutputFiles()
+		}
+		p.output.err = multierr.Combine(p.startErr, p.readyErr, p.waitErr)
+	})
+	return p.output
+}
+
+// closeGRPC closes the gRPC client connected to Falco, if any,
+// and releases its local resources
+func (p *TestProcess) closeGRPC() {
+	if p.output.grpc != nil {
+		if err := p.output.grpc.Close(); err != nil {
+			logrus.WithError(err).Debug("can't close falco grpc client")
+		}
+	}
+	if p.output.opts.grpc != nil {
+		if err := p.output.opts.grpc.cleanup(); err != nil {
+			logrus.WithError(err).Warn("can't cleanup falco grpc client resources")
+		}
+	}
+}
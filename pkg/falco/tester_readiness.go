@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const readyPollInterval = 100 * time.Millisecond
+
+// ReadyCondition is a condition that a running Falco must satisfy
+// in order to be considered ready.
+type ReadyCondition interface {
+	// Wait blocks until the condition is satisfied by the given running
+	// Falco process, or until the context is done. Returns a non-nil error
+	// if the condition can't be satisfied.
+	Wait(ctx context.Context, p *TestProcess) error
+	// String returns a human-readable description of the condition.
+	String() string
+}
+
+// readyResource is a resource acquired by a readiness condition once it is
+// satisfied, such as a client connected to Falco
+type readyResource interface {
+	// attach makes the resource available through the given process
+	attach(p *TestProcess)
+	// release frees the resource when Falco does not become ready
+	release()
+}
+
+// resourceCondition is a ReadyCondition that acquires a resource once
+// satisfied, which is attached to the process only if Falco becomes ready
+type resourceCondition interface {
+	ReadyCondition
+	acquire(ctx context.Context, p *TestProcess) (readyResource, error)
+}
+
+type readyResult struct {
+	res readyResource
+	err error
+}
+
+// NotReadyError is an error representing a running Falco that did not
+// satisfy a readiness condition
+type NotReadyError struct {
+	// Condition is the description of the unsatisfied condition
+	Condition string
+	// Exited is true if Falco exited before becoming ready, and false if
+	// Falco was still running when the readiness timeout expired
+	Exited bool
+	// Timeout is the max duration for which Falco was waited to become ready
+	Timeout time.Duration
+	// Err is the error that made the condition fail
+	Err error
+}
+
+func (e *NotReadyError) Error() string {
+	switch {
+	case e.Exited:
+		return fmt.Sprintf("falco exited early before becoming ready (%s)", e.Condition)
+	case errors.Is(e.Err, context.DeadlineExceeded):
+		return fmt.Sprintf("falco never became ready after %s (%s)", e.Timeout, e.Condition)
+	default:
+		return fmt.Sprintf("falco can't become ready (%s): %s", e.Condition, e.Err)
+	}
+}
+
+func (e *NotReadyError) Unwrap() error {
+	return e.Err
+}
+
+func (p *TestProcess) waitReady(conds []ReadyCondition, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var resources []readyResource
+	for _, c := range conds {
+		logrus.WithField("condition", c.String()).Debug("waiting for falco to be ready")
+		res := make(chan readyResult, 1)
+		go func(c ReadyCondition) {
+			if rc, ok := c.(resourceCondition); ok {
+				r, err := rc.acquire(ctx, p)
+				res <- readyResult{res: r, err: err}
+				return
+			}
+			res <- readyResult{err: c.Wait(ctx, p)}
+		}(c)
+
+		var err error
+		select {
+		case r := <-res:
+			err = r.err
+			if r.res != nil {
+				resources = append(resources, r.res)
+			}
+		case <-p.Exited():
+			err = errNotRunning
+			go releaseLate(res)
+		case <-ctx.Done():
+			err = ctx.Err()
+			go releaseLate(res)
+		}
+		if err != nil {
+			for _, r := range resources {
+				r.release()
+			}
+			res := &NotReadyError{Condition: c.String(), Timeout: timeout, Err: err}
+			// the condition may fail due to either the process exiting or
+			// the timeout expiring, so we need to discriminate which one
+			// happened first. Other failures are returned right away
+			if errors.Is(err, errNotRunning) || (ctx.Err() != nil && errors.Is(err, ctx.Err())) {
+				select {
+				case <-p.Exited():
+					res.Exited = true
+				case <-ctx.Done():
+				}
+			}
+			return res
+		}
+	}
+	for _, r := range resources {
+		r.attach(p)
+	}
+	return nil
+}
+
+// releaseLate releases the resource acquired by a condition that is no
+// longer waited for, if any
+func releaseLate(res <-chan readyResult) {
+	if r := <-res; r.res != nil {
+		r.res.release()
+	}
+}
+
+// poll runs a check periodically, up until it returns true, a non-nil
+// error, or the context is done
+func poll(ctx context.Context, check func() (bool, error)) error {
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := check()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+type logLineCondition struct {
+	rgx *regexp.Regexp
+}
+
+// LogLineMatches is a readiness condition satisfied when Falco
+// prints a line matching a given regular expression either on stdout or stderr.
+func LogLineMatches(rgx *regexp.Regexp) ReadyCondition {
+	return &logLineCondition{rgx: rgx}
+}
+
+func (l *logLineCondition) Wait(ctx context.Context, p *TestProcess) error {
+	matched := make(chan bool, 2)
+	for _, r := range []io.Reader{p.Stdout(), p.Stderr()} {
+		go func(r io.Reader) {
+			scanner := bufio.NewScanner(r)
+			for scanner.Scan() {
+				if l.rgx.MatchString(scanner.Text()) {
+					matched <- true
+					return
+				}
+			}
+			matched <- false
+		}(r)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case ok := <-matched:
+			if ok {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	// note: both outputs end once the process exits
+	return fmt.Errorf("no log line matched %s: %w", l.rgx.String(), errNotRunning)
+}
+
+func (l *logLineCondition) String() string {
+	return fmt.Sprintf("log line matches '%s'", l.rgx.String())
+}
+
+type httpEndpointCondition struct {
+	url string
+}
+
+// HTTPEndpointUp is a readiness condition satisfied when an HTTP GET
+// request on the given URL succeeds with a 2xx status code.
+func HTTPEndpointUp(url string) ReadyCondition {
+	return &httpEndpointCondition{url: url}
+}
+
+func (h *httpEndpointCondition) Wait(ctx context.Context, p *TestProcess) error {
+	u, err := url.Parse(h.url)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported url scheme '%s'", u.Scheme)
+	}
+	return poll(ctx, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+		if err != nil {
+			return false, err
+		}
+		// note: requests fail until the endpoint is up
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return false, nil
+		}
+		defer res.Body.Close()
+		return res.StatusCode >= 200 && res.StatusCode < 300, nil
+	})
+}
+
+func (h *httpEndpointCondition) String() string {
+	return fmt.Sprintf("http endpoint '%s' is up", h.url)
+}
+
+type unixSocketCondition struct {
+	path string
+}
+
+// UnixSocketExists is a readiness condition satisfied when a unix socket
+// exists at the given path in the local filesystem.
+func UnixSocketExists(path string) ReadyCondition {
+	return &unixSocketCondition{path: path}
+}
+
+func (u *unixSocketCondition) Wait(ctx context.Context, p *TestProcess) error {
+	return poll(ctx, func() (bool, error) {
+		info, err := os.Stat(u.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return info.Mode()&os.ModeSocket != 0, nil
+	})
+}
+
+func (u *unixSocketCondition) String() string {
+	return fmt.Sprintf("unix socket '%s' exists", u.path)
+}
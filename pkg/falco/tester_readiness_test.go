@@ -0,0 +1,262 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/falcosecurity/testing/pkg/run"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubRunner is a runner that starts a fake process printing the given
+// output on stdout, and exiting after the given delay or once signaled
+// if the delay is zero
+type stubRunner struct {
+	stdout    string
+	exitAfter time.Duration
+}
+
+func (s *stubRunner) WorkDir() string {
+	return "/tmp"
+}
+
+func (s *stubRunner) Run(ctx context.Context, options ...run.RunnerOption) error {
+	p, err := s.Start(ctx, options...)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+func (s *stubRunner) Start(ctx context.Context, options ...run.RunnerOption) (run.Process, error) {
+	p := &stubProcess{stdout: s.stdout, exit: make(chan struct{})}
+	go func() {
+		var timeout <-chan time.Time
+		if s.exitAfter > 0 {
+			timeout = time.After(s.exitAfter)
+		}
+		select {
+		case <-timeout:
+		case <-ctx.Done():
+		case <-p.exit:
+			return
+		}
+		p.close()
+	}()
+	return p, nil
+}
+
+type stubProcess struct {
+	stdout string
+	exit   chan struct{}
+	once   sync.Once
+}
+
+func (s *stubProcess) close() {
+	s.once.Do(func() { close(s.exit) })
+}
+
+func (s *stubProcess) Wait() error {
+	<-s.exit
+	return nil
+}
+
+func (s *stubProcess) Signal(sig os.Signal) error {
+	s.close()
+	return nil
+}
+
+func (s *stubProcess) Pid() int {
+	return 1
+}
+
+func (s *stubProcess) Stdout() io.Reader {
+	return io.MultiReader(strings.NewReader(s.stdout), &stubExitReader{exit: s.exit})
+}
+
+func (s *stubProcess) Stderr() io.Reader {
+	return &stubExitReader{exit: s.exit}
+}
+
+func (s *stubProcess) OutputFiles() []run.FileAccessor {
+	return nil
+}
+
+// stubExitReader blocks until the process exits and then returns io.EOF
+type stubExitReader struct {
+	exit chan struct{}
+}
+
+func (s *stubExitReader) Read(p []byte) (int, error) {
+	<-s.exit
+	return 0, io.EOF
+}
+
+// stubResourceCondition is a condition that acquires a resource after
+// the given delay, and that counts how many are attached and released
+type stubResourceCondition struct {
+	delay    time.Duration
+	attached atomic.Int32
+	released atomic.Int32
+}
+
+type stubResource struct {
+	cond *stubResourceCondition
+}
+
+func (s *stubResource) attach(p *TestProcess) {
+	s.cond.attached.Add(1)
+}
+
+func (s *stubResource) release() {
+	s.cond.released.Add(1)
+}
+
+func (s *stubResourceCondition) Wait(ctx context.Context, p *TestProcess) error {
+	return errors.New("not implemented")
+}
+
+func (s *stubResourceCondition) acquire(ctx context.Context, p *TestProcess) (readyResource, error) {
+	// note: the resource is acquired regardless of the context, which
+	// simulates a condition finishing after not being waited for anymore
+	time.Sleep(s.delay)
+	return &stubResource{cond: s}, nil
+}
+
+func (s *stubResourceCondition) String() string {
+	return "stub resource"
+}
+
+func startStub(t *testing.T, runner *stubRunner, options ...TestOption) (*TestProcess, time.Duration) {
+	start := time.Now()
+	p := Start(runner, options...)
+	elapsed := time.Since(start)
+	t.Cleanup(func() {
+		p.Signal(os.Interrupt)
+		p.Wait()
+	})
+	return p, elapsed
+}
+
+func requireNotReady(t *testing.T, p *TestProcess) *NotReadyError {
+	var res *NotReadyError
+	require.ErrorAs(t, p.Err(), &res)
+	return res
+}
+
+func TestWaitReady(t *testing.T) {
+	t.Parallel()
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer srv.Close()
+		socket := filepath.Join(t.TempDir(), "falco.sock")
+		l, err := net.Listen("unix", socket)
+		require.NoError(t, err)
+		defer l.Close()
+
+		p, _ := startStub(t, &stubRunner{stdout: "starting\nready\n"}, WithReadyWhen(
+			LogLineMatches(regexp.MustCompile(`^ready$`)),
+			HTTPEndpointUp(srv.URL),
+			UnixSocketExists(socket),
+		))
+		assert.NoError(t, p.Err())
+	})
+
+	t.Run("exited", func(t *testing.T) {
+		t.Parallel()
+		for _, cond := range []ReadyCondition{
+			LogLineMatches(regexp.MustCompile(`^ready$`)),
+			UnixSocketExists(filepath.Join(t.TempDir(), "falco.sock")),
+		} {
+			p, _ := startStub(t, &stubRunner{stdout: "starting\n", exitAfter: 100 * time.Millisecond},
+				WithReadyWhen(cond), WithReadyTimeout(time.Minute))
+			err := requireNotReady(t, p)
+			assert.True(t, err.Exited)
+			assert.Equal(t, cond.String(), err.Condition)
+			assert.Contains(t, err.Error(), "exited early")
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		t.Parallel()
+		for _, cond := range []ReadyCondition{
+			LogLineMatches(regexp.MustCompile(`^ready$`)),
+			UnixSocketExists(filepath.Join(t.TempDir(), "falco.sock")),
+		} {
+			p, _ := startStub(t, &stubRunner{stdout: "starting\n"},
+				WithReadyWhen(cond), WithReadyTimeout(200*time.Millisecond))
+			err := requireNotReady(t, p)
+			assert.False(t, err.Exited)
+			assert.Equal(t, 200*time.Millisecond, err.Timeout)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.Contains(t, err.Error(), "never became ready")
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		t.Parallel()
+		p, elapsed := startStub(t, &stubRunner{}, WithReadyWhen(HTTPEndpointUp("ftp://127.0.0.1")),
+			WithReadyTimeout(time.Minute))
+		err := requireNotReady(t, p)
+		assert.False(t, err.Exited)
+		require.Error(t, err.Err)
+		assert.Contains(t, err.Error(), err.Err.Error())
+		assert.Less(t, elapsed, 10*time.Second)
+	})
+
+	t.Run("resources", func(t *testing.T) {
+		t.Parallel()
+		cond := &stubResourceCondition{}
+		p, _ := startStub(t, &stubRunner{}, WithReadyWhen(cond))
+		require.NoError(t, p.Err())
+		assert.EqualValues(t, 1, cond.attached.Load())
+		assert.EqualValues(t, 0, cond.released.Load())
+
+		// resources acquired after the timeout are released, and
+		// the ones acquired before a failing condition are too
+		late := &stubResourceCondition{delay: 300 * time.Millisecond}
+		p, _ = startStub(t, &stubRunner{}, WithReadyWhen(late), WithReadyTimeout(100*time.Millisecond))
+		requireNotReady(t, p)
+		first := &stubResourceCondition{}
+		p, _ = startStub(t, &stubRunner{}, WithReadyWhen(first, HTTPEndpointUp("ftp://127.0.0.1")))
+		requireNotReady(t, p)
+		assert.Eventually(t, func() bool {
+			return late.released.Load() == 1 && first.released.Load() == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.EqualValues(t, 0, late.attached.Load())
+		assert.EqualValues(t, 0, first.attached.Load())
+	})
+}
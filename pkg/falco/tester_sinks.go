@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/falcosecurity/testing/pkg/certs"
+	"github.com/falcosecurity/testing/pkg/run"
+)
+
+// HTTPOutputSink is an HTTP endpoint to which Falco sends alerts
+// through the `http_output` output channel (see the sinks package).
+type HTTPOutputSink interface {
+	// URL returns the URL to which Falco sends alerts
+	URL() string
+	//
+	// Certificates returns the certificates used by the endpoint
+	// for TLS, or nil if TLS is not used
+	Certificates() *certs.Bundle
+	//
+	// MTLS returns true if the endpoint requires clients to
+	// authenticate with the client certificate of Certificates
+	MTLS() bool
+}
+
+// WithHTTPOutput runs Falco with the `http_output` output channel enabled
+// and configured for sending alerts to the given sink, including the
+// certificates required for TLS and mutual TLS. The URL of the sink must
+// be reachable from where Falco runs. Since sinks listen on the loopback
+// interface by default, this means that Falco must either run on the host
+// or in a container using the host's network (e.g. see NetworkMode in
+// run.DockerRunnerOptions), unless the sink listens on another address.
+func WithHTTPOutput(sink HTTPOutputSink) TestOption {
+	return func(o *testOptions) {
+		o.args = append(o.args, "-o", "http_output.enabled=true")
+		o.args = append(o.args, "-o", "http_output.url="+sink.URL())
+		bundle := sink.Certificates()
+		if bundle == nil {
+			return
+		}
+		o.setups = append(o.setups, func(runner run.Runner, o *testOptions) error {
+			addFile := func(option, name string, content []byte) {
+				o.args = append(o.args, "-o", option+"="+path.Join(runner.WorkDir(), name))
+				o.files = append(o.files, run.NewBytesFileAccessor(name, content))
+			}
+			o.args = append(o.args, "-o", "http_output.insecure=false")
+			addFile("http_output.ca_cert", "http_output/ca.crt", bundle.CACert)
+			if sink.MTLS() {
+				o.args = append(o.args, "-o", "http_output.mtls=true")
+				addFile("http_output.client_cert", "http_output/client.crt", bundle.ClientCert)
+				addFile("http_output.client_key", "http_output/client.key", bundle.ClientKey)
+			}
+			return nil
+		})
+	}
+}
+
+// WithSyslogOutput runs Falco with the `syslog_output` output channel
+// enabled. Falco sends syslog messages through the syslog device of the
+// host or container in which it runs (e.g. see SyslogSink in the sinks
+// package for receiving them).
+func WithSyslogOutput() TestOption {
+	return func(o *testOptions) {
+		o.args = append(o.args, "-o", "syslog_output.enabled=true")
+	}
+}
+
+// programOutputRecorder is a shell script used as the program of the
+// `program_output` output channel. Each time it is spawned, it appends
+// a "spawn" entry to the record file next to it, followed by a "line"
+// entry for each line it receives through its standard input.
+const programOutputRecorder = `#!/bin/sh
+record="$(dirname "$0")/` + programOutputRecordFile + `"
+echo "spawn $$" >> "$record"
+while IFS= read -r line || [ -n "$line" ]; do
+	printf 'line %s\n' "$line" >> "$record"
+done
+`
+
+const (
+	programOutputDir        = "program_output"
+	programOutputScriptFile = "recorder.sh"
+	programOutputRecordFile = "record.txt"
+)
+
+// WithProgramOutput runs Falco with the `program_output` output channel
+// enabled, and with a recorder program storing the alerts Falco sends to it.
+// The recorded alerts and the number of times the program was spawned are
+// then available through TestOutput.ProgramOutput. If keepAlive is true,
+// Falco is expected to spawn the program only once for all the alerts.
+// The program requires "/bin/sh" and "dirname" to be available.
+func WithProgramOutput(keepAlive bool) TestOption {
+	return func(o *testOptions) {
+		o.programOutput = path.Join(programOutputDir, programOutputRecordFile)
+		o.args = append(o.args, "-o", "program_output.enabled=true")
+		o.args = append(o.args, "-o", fmt.Sprintf("program_output.keep_alive=%t", keepAlive))
+		o.runOpts = append(o.runOpts, run.WithOutputFiles(o.programOutput))
+		o.setups = append(o.setups, func(runner run.Runner, o *testOptions) error {
+			script := run.NewStringFileAccessor(path.Join(programOutputDir, programOutputScriptFile), programOutputRecorder)
+			o.args = append(o.args, "-o", "program_output.program=/bin/sh "+path.Join(runner.WorkDir(), script.Name()))
+			o.files = append(o.files, script)
+			return nil
+		})
+	}
+}
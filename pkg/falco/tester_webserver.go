@@ -0,0 +1,238 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/falcosecurity/testing/pkg/certs"
+	"github.com/falcosecurity/testing/pkg/run"
+)
+
+const (
+	// HealthzPath is the default path of the endpoint of the webserver of
+	// Falco used as liveness probe in Kubernetes deployments
+	HealthzPath = "/healthz"
+	//
+	// VersionsPath is the path of the endpoint of the webserver of
+	// Falco reporting the versions of Falco and of its components
+	VersionsPath = "/versions"
+)
+
+type webserverOptions struct {
+	port   int
+	tls    bool
+	client *WebserverClient
+}
+
+// WithWebserver runs Falco with its embedded webserver enabled, listening
+// on a TCP port that is free when Falco is started, so that multiple
+// Falco instances can run in parallel. The port is made reachable from the
+// host with run.WithPorts, and a client of the webserver is available through
+// TestProcess.Webserver and TestOutput.Webserver. Use WebserverEndpointUp
+// with HealthzPath for waiting for the webserver to be up.
+func WithWebserver() TestOption {
+	return func(o *testOptions) {
+		if o.webserver == nil {
+			o.webserver = &webserverOptions{}
+			o.setups = append(o.setups, o.webserver.setup)
+		}
+	}
+}
+
+// WithWebserverTLS is the same as WithWebserver, but the webserver serves
+// HTTPS with a certificate signed by a generated CA, which is trusted
+// by the webserver client.
+func WithWebserverTLS() TestOption {
+	return func(o *testOptions) {
+		WithWebserver()(o)
+		o.webserver.tls = true
+	}
+}
+
+// setup picks the port on which the webserver listens, and prepares
+// the certificate used for TLS and the client connecting to it
+func (w *webserverOptions) setup(runner run.Runner, o *testOptions) error {
+	port, err := o.reserveTCPPort()
+	if err != nil {
+		return err
+	}
+	w.port = port
+	w.client = &WebserverClient{
+		url:    fmt.Sprintf("http://127.0.0.1:%d", port),
+		client: http.DefaultClient,
+	}
+	o.args = append(o.args, "-o", "webserver.enabled=true")
+	o.args = append(o.args, "-o", "webserver.listen_port="+strconv.Itoa(port))
+	o.args = append(o.args, "-o", "webserver.ssl_enabled="+strconv.FormatBool(w.tls))
+	o.runOpts = append(o.runOpts, run.WithPorts(port))
+	if !w.tls {
+		return nil
+	}
+
+	bundle, err := certs.Generate()
+	if err != nil {
+		return err
+	}
+	config, err := bundle.ClientTLSConfig("")
+	if err != nil {
+		return err
+	}
+	w.client.url = fmt.Sprintf("https://127.0.0.1:%d", port)
+	w.client.client = &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+
+	// note: Falco expects both the key and the certificate in the same file
+	cert := run.NewBytesFileAccessor("webserver/falco.pem", append(append([]byte{}, bundle.ServerKey...), bundle.ServerCert...))
+	o.args = append(o.args, "-o", "webserver.ssl_certificate="+path.Join(runner.WorkDir(), cert.Name()))
+	o.files = append(o.files, cert)
+	return nil
+}
+
+// Webserver returns a client of the webserver of the running Falco.
+// Returns nil if Falco wasn't run with WithWebserver, or if it could
+// not be started.
+func (p *TestProcess) Webserver() *WebserverClient {
+	return p.output.Webserver()
+}
+
+// Webserver returns a client of the webserver of Falco, which can't be
+// used once Falco has exited. Returns nil if Falco wasn't run with
+// WithWebserver, or if it could not be started.
+func (t *TestOutput) Webserver() *WebserverClient {
+	if t.opts.webserver == nil {
+		return nil
+	}
+	return t.opts.webserver.client
+}
+
+// WebserverURL returns the base URL of the webserver of Falco (e.g.
+// "http://127.0.0.1:8765"). Returns an empty string if Falco wasn't run
+// with WithWebserver, or if it could not be started.
+func (t *TestOutput) WebserverURL() string {
+	if w := t.Webserver(); w != nil {
+		return w.URL()
+	}
+	return ""
+}
+
+// WebserverClient is a client of the webserver of a running Falco.
+type WebserverClient struct {
+	url    string
+	client *http.Client
+}
+
+// URL returns the base URL of the webserver.
+func (w *WebserverClient) URL() string {
+	return w.url
+}
+
+// Get sends an HTTP GET request on the given path of the webserver and
+// returns the body of the response. Returns a non-nil error if the
+// response has not a 2xx status code.
+func (w *WebserverClient) Get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.url+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("webserver endpoint '%s' returned status %d", path, res.StatusCode)
+	}
+	return body, nil
+}
+
+// Healthz checks the health of Falco through the liveness probe endpoint
+// of the webserver. Returns a non-nil error if Falco is not healthy.
+func (w *WebserverClient) Healthz(ctx context.Context) error {
+	body, err := w.Get(ctx, HealthzPath)
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return err
+	}
+	if res.Status != "ok" {
+		return fmt.Errorf("falco is not healthy (status '%s')", res.Status)
+	}
+	return nil
+}
+
+// Versions returns the versions of Falco and of its
+// components reported by the webserver.
+func (w *WebserverClient) Versions(ctx context.Context) (*Versions, error) {
+	body, err := w.Get(ctx, VersionsPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseVersions(body)
+}
+
+// Metrics returns the Prometheus metrics reported by the webserver.
+// Falco must be run with WithPrometheusMetrics.
+func (w *WebserverClient) Metrics(ctx context.Context) (*Metrics, error) {
+	body, err := w.Get(ctx, MetricsPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMetrics(string(body))
+}
+
+type webserverEndpointCondition struct {
+	path string
+}
+
+// WebserverEndpointUp is a readiness condition satisfied when an HTTP GET
+// request on the given path of the webserver of Falco (e.g. HealthzPath)
+// succeeds with a 2xx status code. Falco must be run with WithWebserver.
+func WebserverEndpointUp(path string) ReadyCondition {
+	return &webserverEndpointCondition{path: path}
+}
+
+func (w *webserverEndpointCondition) Wait(ctx context.Context, p *TestProcess) error {
+	client := p.Webserver()
+	if client == nil {
+		return fmt.Errorf("falco is not running with the webserver enabled")
+	}
+	// note: requests fail until the webserver is up
+	return poll(ctx, func() (bool, error) {
+		_, err := client.Get(ctx, w.path)
+		return err == nil, nil
+	})
+}
+
+func (w *webserverEndpointCondition) String() string {
+	return fmt.Sprintf("webserver endpoint '%s' is up", w.path)
+}
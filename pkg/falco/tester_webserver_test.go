@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/falcosecurity/testing/pkg/certs"
+	"github.com/falcosecurity/testing/pkg/run"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testVersions = `{"default_driver_version":"7.0.0+driver","driver_api_version":"8.0.0",` +
+	`"driver_schema_version":"2.0.0","engine_version":"31","engine_version_semver":"0.31.0",` +
+	`"falco_version":"0.37.0","libs_version":"0.14.1","plugin_api_version":"3.2.0",` +
+	`"plugin_versions":{"dummy":"0.1.0"}}`
+
+func testWebserverHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case HealthzPath:
+		w.Write([]byte(`{"status": "ok"}`))
+	case VersionsPath:
+		w.Write([]byte(testVersions))
+	case MetricsPath:
+		w.Write([]byte(testMetrics))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestWithWebserver(t *testing.T) {
+	t.Parallel()
+	runner, err := run.NewExecutableRunner("/bin/true")
+	require.NoError(t, err)
+
+	t.Run("plain", func(t *testing.T) {
+		t.Parallel()
+		opts := &testOptions{}
+		out := &TestOutput{opts: opts}
+		WithWebserver()(opts)
+		WithPrometheusMetrics()(opts)
+		require.Len(t, opts.setups, 1)
+		assert.Nil(t, out.Webserver())
+		assert.Empty(t, out.WebserverURL())
+		assert.Empty(t, out.MetricsURL())
+
+		require.NoError(t, opts.setups[0](runner, opts))
+		require.NotZero(t, opts.webserver.port)
+		port := strconv.Itoa(opts.webserver.port)
+		assert.Contains(t, opts.args, "webserver.listen_port="+port)
+		assert.Contains(t, opts.args, "webserver.ssl_enabled=false")
+		assert.Len(t, opts.runOpts, 1)
+		assert.Empty(t, opts.files)
+		assert.Equal(t, "http://127.0.0.1:"+port, out.WebserverURL())
+		assert.Equal(t, "http://127.0.0.1:"+port+"/metrics", out.MetricsURL())
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		t.Parallel()
+		opts := &testOptions{}
+		out := &TestOutput{opts: opts}
+		WithWebserverTLS()(opts)
+		WithPrometheusMetrics()(opts)
+		require.Len(t, opts.setups, 1)
+
+		require.NoError(t, opts.setups[0](runner, opts))
+		assert.Contains(t, opts.args, "webserver.ssl_enabled=true")
+		require.Len(t, opts.files, 1)
+		assert.Equal(t, "webserver/falco.pem", opts.files[0].Name())
+		assert.Contains(t, opts.args, "webserver.ssl_certificate="+runner.WorkDir()+"/webserver/falco.pem")
+		assert.True(t, strings.HasPrefix(out.WebserverURL(), "https://127.0.0.1:"))
+		assert.True(t, strings.HasPrefix(out.MetricsURL(), "https://127.0.0.1:"))
+	})
+}
+
+func TestWebserverClient(t *testing.T) {
+	t.Parallel()
+	checkClient := func(t *testing.T, client *WebserverClient) {
+		ctx := context.Background()
+		require.NoError(t, client.Healthz(ctx))
+
+		versions, err := client.Versions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "0.37.0", versions.FalcoVersion)
+		assert.Equal(t, "0.14.1", versions.LibsVersion)
+		assert.Equal(t, "8.0.0", versions.DriverAPIVersion)
+		assert.Equal(t, "31", versions.EngineVersion)
+		assert.Equal(t, map[string]string{"dummy": "0.1.0"}, versions.PluginVersions)
+
+		m, err := client.Metrics(ctx)
+		require.NoError(t, err)
+		assert.True(t, m.Has("falcosecurity_scap_n_evts_total"))
+
+		_, err = client.Get(ctx, "/other")
+		assert.Error(t, err)
+	}
+
+	t.Run("plain", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.HandlerFunc(testWebserverHandler))
+		defer srv.Close()
+		checkClient(t, &WebserverClient{url: srv.URL, client: http.DefaultClient})
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		t.Parallel()
+		bundle, err := certs.Generate()
+		require.NoError(t, err)
+		serverConfig, err := bundle.ServerTLSConfig(false)
+		require.NoError(t, err)
+		clientConfig, err := bundle.ClientTLSConfig("")
+		require.NoError(t, err)
+
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(testWebserverHandler))
+		srv.TLS = serverConfig
+		srv.StartTLS()
+		defer srv.Close()
+		checkClient(t, &WebserverClient{
+			url:    srv.URL,
+			client: &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}},
+		})
+
+		// the CA of the bundle must be trusted for connecting
+		_, err = (&WebserverClient{url: srv.URL, client: http.DefaultClient}).Get(context.Background(), HealthzPath)
+		assert.Error(t, err)
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		t.Parallel()
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"status": "starting"}`))
+		}))
+		defer srv.Close()
+		assert.Error(t, (&WebserverClient{url: srv.URL, client: http.DefaultClient}).Healthz(context.Background()))
+	})
+}
+
+func TestScrapeMetrics(t *testing.T) {
+	t.Parallel()
+	// the webserver is slower than the default scrape interval
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * DefaultMetricsScrapeInterval)
+		testWebserverHandler(w, r)
+	}))
+	defer srv.Close()
+
+	// note: the process is not running, so metrics are scraped only once
+	p := &TestProcess{output: &TestOutput{opts: &testOptions{
+		webserver: &webserverOptions{client: &WebserverClient{url: srv.URL, client: http.DefaultClient}},
+	}}}
+	p.scrapeMetrics(context.Background(), 4*DefaultMetricsScrapeInterval)
+	p.wg.Wait()
+	require.Len(t, p.output.Metrics(), 1)
+	assert.True(t, p.output.Metrics()[0].Has("falcosecurity_scap_n_evts_total"))
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package falco
+
+import "encoding/json"
+
+// Versions represents the versions of Falco and of its components, as
+// reported both by the `/versions` endpoint of the webserver and by
+// the `--version` command with JSON output enabled.
+type Versions struct {
+	FalcoVersion         string `json:"falco_version"`
+	LibsVersion          string `json:"libs_version"`
+	PluginAPIVersion     string `json:"plugin_api_version"`
+	DriverAPIVersion     string `json:"driver_api_version"`
+	DriverSchemaVersion  string `json:"driver_schema_version"`
+	DefaultDriverVersion string `json:"default_driver_version"`
+	EngineVersion        string `json:"engine_version"`
+	EngineVersionSemver  string `json:"engine_version_semver,omitempty"`
+	EngineFieldsChecksum string `json:"engine_fields_checksum,omitempty"`
+	//
+	// PluginVersions are the versions of the loaded plugins,
+	// indexed by plugin name
+	PluginVersions map[string]string `json:"plugin_versions,omitempty"`
+}
+
+// ParseVersions parses the versions of Falco from their JSON representation.
+func ParseVersions(data []byte) (*Versions, error) {
+	res := &Versions{}
+	if err := json.Unmarshal(data, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
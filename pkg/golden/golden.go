@@ -0,0 +1,253 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+
+	"github.com/falcosecurity/testing/pkg/falco"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// DefaultDir is the directory in which golden files are stored, relative
+	// to the directory of the source file of the test using them
+	DefaultDir = "testdata/golden"
+	//
+	// NormalizedTime is the placeholder used for normalized time values
+	NormalizedTime = "<TIME>"
+	//
+	// NormalizedHostname is the placeholder used for normalized hostnames
+	NormalizedHostname = "<HOSTNAME>"
+	//
+	// NormalizedPid is the placeholder used for normalized process and thread identifiers
+	NormalizedPid = "<PID>"
+)
+
+var (
+	// Update is true if golden files should be updated with the actual
+	// values instead of being compared with them
+	Update = false
+
+	timeRegex = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?|\b\d{2}:\d{2}:\d{2}\.\d{9}\b`)
+	//
+	// pidRegex matches the process and thread identifiers printed
+	// in the output of the alerts (e.g. "pid=1234")
+	pidRegex = regexp.MustCompile(`\b(pid|ppid|vpid|apid|tid|vtid)=\d+\b`)
+)
+
+// TestingT is the subset of testing.TB used for asserting golden files
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+func init() {
+	flag.BoolVar(&Update, "update-golden", Update, "Update golden files with the actual outputs of the tests")
+}
+
+type options struct {
+	dir      string
+	fields   map[string]string
+	patterns []replacement
+}
+
+type replacement struct {
+	rgx  *regexp.Regexp
+	repl string
+}
+
+// Option is an option for comparing values with golden files
+type Option func(*options)
+
+// WithDir stores golden files in the given directory. Relative
+// directories are resolved from the directory of the test's source file.
+func WithDir(dir string) Option {
+	return func(o *options) { o.dir = dir }
+}
+
+// WithNormalizedField replaces the value of all the fields with the given
+// key with a placeholder before comparison.
+func WithNormalizedField(key, placeholder string) Option {
+	return func(o *options) { o.fields[key] = placeholder }
+}
+
+// WithNormalizedPattern replaces all the string sub-values matching a given
+// regular expression with a replacement before comparison.
+func WithNormalizedPattern(rgx *regexp.Regexp, replacement string) Option {
+	return func(o *options) { o.patterns = append(o.patterns, newReplacement(rgx, replacement)) }
+}
+
+func newReplacement(rgx *regexp.Regexp, repl string) replacement {
+	return replacement{rgx: rgx, repl: repl}
+}
+
+// AssertDetections compares a list of Falco detections with the content of
+// a golden file with the given name, after normalizing volatile fields such
+// as time, hostname, and process identifiers, both in the output fields and
+// in the output of the alerts (e.g. "pid=1234").
+func AssertDetections(t TestingT, name string, d falco.Detections, opts ...Option) bool {
+	t.Helper()
+	if d == nil {
+		d = falco.Detections{}
+	}
+	return assertGolden(t, callerDir(), name, d, opts...)
+}
+
+// AssertRulesetDescription compares the description of a Falco ruleset with
+// the content of a golden file with the given name.
+func AssertRulesetDescription(t TestingT, name string, d *falco.RulesetDescription, opts ...Option) bool {
+	t.Helper()
+	return assertGolden(t, callerDir(), name, d, opts...)
+}
+
+// AssertRuleValidation compares the validation results of Falco rules files
+// with the content of a golden file with the given name.
+func AssertRuleValidation(t TestingT, name string, v *falco.RuleValidation, opts ...Option) bool {
+	t.Helper()
+	return assertGolden(t, callerDir(), name, v, opts...)
+}
+
+// Assert compares any json-serializable value with the content of a golden
+// file with the given name, after normalizing volatile fields.
+func Assert(t TestingT, name string, v interface{}, opts ...Option) bool {
+	t.Helper()
+	return assertGolden(t, callerDir(), name, v, opts...)
+}
+
+// callerDir returns the directory of the source file of the function
+// calling the public API of this package
+func callerDir() string {
+	_, file, _, ok := runtime.Caller(2)
+	if !ok {
+		return ""
+	}
+	return filepath.Dir(file)
+}
+
+func assertGolden(t TestingT, baseDir, name string, v interface{}, opts ...Option) bool {
+	t.Helper()
+	o := &options{
+		dir: DefaultDir,
+		fields: map[string]string{
+			"time":               NormalizedTime,
+			"hostname":           NormalizedHostname,
+			"evt.time":           NormalizedTime,
+			"evt.time.iso8601":   NormalizedTime,
+			"evt.rawtime":        NormalizedTime,
+			"evt.datetime":       NormalizedTime,
+			"proc.pid":           NormalizedPid,
+			"proc.ppid":          NormalizedPid,
+			"proc.vpid":          NormalizedPid,
+			"proc.apid":          NormalizedPid,
+			"thread.tid":         NormalizedPid,
+			"thread.vtid":        NormalizedPid,
+			"evt.hostname":       NormalizedHostname,
+			"container.hostname": NormalizedHostname,
+		},
+		patterns: []replacement{
+			newReplacement(timeRegex, NormalizedTime),
+			newReplacement(pidRegex, "${1}="+NormalizedPid),
+		},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	actual, err := normalize(v, o)
+	if !assert.NoError(t, err, "can't normalize value for golden file %s", name) {
+		return false
+	}
+
+	dir := o.dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(baseDir, dir)
+	}
+	path := filepath.Join(dir, name)
+	if Update {
+		logrus.WithField("path", path).Info("updating golden file")
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return assert.NoError(t, err, "can't create golden files dir")
+		}
+		return assert.NoError(t, os.WriteFile(path, actual, 0644), "can't update golden file %s", path)
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		return assert.Fail(t, "can't read golden file", "%s (run with -update-golden to create it)", err.Error())
+	}
+	return assert.Equal(t, string(expected), string(actual),
+		"value differs from golden file %s (run with -update-golden to update it)", path)
+}
+
+// normalize serializes a value in indented JSON after replacing all its
+// volatile fields with placeholders
+func normalize(v interface{}, o *options) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(normalizeValue(generic, o)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func normalizeValue(v interface{}, o *options) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			if placeholder, ok := o.fields[k]; ok && field != nil {
+				value[k] = placeholder
+				continue
+			}
+			value[k] = normalizeValue(field, o)
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = normalizeValue(value[i], o)
+		}
+		return value
+	case string:
+		for _, p := range o.patterns {
+			value = p.rgx.ReplaceAllString(value, p.repl)
+		}
+		return value
+	default:
+		return value
+	}
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package golden
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/falcosecurity/testing/pkg/falco"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT records the failures of the assertions on golden files
+type fakeT struct {
+	failed bool
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Errorf(format string, args ...interface{}) {
+	f.failed = true
+}
+
+const testDetections = `{"hostname":"host-a","output":"2016-08-04T16:17:57.881781397+0000: Warning An open was seen (command=cat /dev/null pid=1234)","priority":"Warning","rule":"open_from_cat","source":"syscall","tags":["filesystem"],"time":"2016-08-04T16:17:57.881781397Z","output_fields":{"evt.time.iso8601":1470327477881781397,"proc.cmdline":"cat /dev/null","proc.pid":1234}}
+`
+
+func TestNormalize(t *testing.T) {
+	o := &options{
+		fields: map[string]string{
+			"time":     NormalizedTime,
+			"hostname": NormalizedHostname,
+			"proc.pid": NormalizedPid,
+		},
+		patterns: []replacement{newReplacement(timeRegex, NormalizedTime)},
+	}
+	res, err := normalize(falco.ParseDetections(testDetections), o)
+	require.NoError(t, err)
+	assert.Contains(t, string(res), `"output": "<TIME>: Warning An open was seen (command=cat /dev/null pid=1234)"`)
+
+	o.patterns = append(o.patterns, newReplacement(pidRegex, "${1}="+NormalizedPid))
+	res, err = normalize(falco.ParseDetections(testDetections), o)
+	require.NoError(t, err)
+	assert.Contains(t, string(res), `"time": "<TIME>"`)
+	assert.Contains(t, string(res), `"hostname": "<HOSTNAME>"`)
+	assert.Contains(t, string(res), `"proc.pid": "<PID>"`)
+	assert.Contains(t, string(res), `"output": "<TIME>: Warning An open was seen (command=cat /dev/null pid=<PID>)"`)
+	assert.Contains(t, string(res), `"proc.cmdline": "cat /dev/null"`)
+	assert.NotContains(t, string(res), "host-a")
+}
+
+func TestAssertDetections(t *testing.T) {
+	dir := t.TempDir()
+	detections := falco.ParseDetections(testDetections)
+	defer func(update bool) { Update = update }(Update)
+
+	// golden file must exist
+	Update = false
+	mockT := &fakeT{}
+	assert.False(t, AssertDetections(mockT, "detections.json", detections, WithDir(dir)))
+	assert.True(t, mockT.failed)
+
+	// golden file gets created when updating
+	Update = true
+	assert.True(t, AssertDetections(t, "detections.json", detections, WithDir(dir)))
+	assert.FileExists(t, filepath.Join(dir, "detections.json"))
+
+	// volatile fields do not affect the comparison
+	Update = false
+	detections[0].Hostname = "host-b"
+	detections[0].Output = strings.Replace(detections[0].Output, "pid=1234", "pid=5678", 1)
+	assert.True(t, AssertDetections(t, "detections.json", detections, WithDir(dir)))
+
+	// other fields do
+	detections[0].Rule = "other_rule"
+	mockT = &fakeT{}
+	assert.False(t, AssertDetections(mockT, "detections.json", detections, WithDir(dir)))
+	assert.True(t, mockT.failed)
+
+}
@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package rules
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/falcosecurity/testing/pkg/run"
+	"gopkg.in/yaml.v3"
+)
+
+// OverrideMode is the way in which a field of a rules file item overrides
+// the same field of an item with the same name defined previously
+type OverrideMode string
+
+const (
+	// OverrideAppend appends the field value to the previously-defined one
+	OverrideAppend OverrideMode = "append"
+	//
+	// OverrideReplace replaces the previously-defined field value
+	OverrideReplace OverrideMode = "replace"
+)
+
+// Item is a top-level item of a Falco rules file
+type Item interface {
+	item()
+}
+
+// RequiredEngineVersion is an item requiring a minimum Falco engine version
+type RequiredEngineVersion struct {
+	Version string `yaml:"required_engine_version"`
+}
+
+// PluginVersion represents the version requirement of a given plugin,
+// with optional alternatives that can satisfy the same requirement.
+type PluginVersion struct {
+	Name         string          `yaml:"name"`
+	Version      string          `yaml:"version"`
+	Alternatives []PluginVersion `yaml:"alternatives,omitempty"`
+}
+
+// RequiredPluginVersions is an item requiring one or more plugins
+// to be loaded with a minimum version
+type RequiredPluginVersions struct {
+	Plugins []PluginVersion `yaml:"required_plugin_versions"`
+}
+
+// List is a list item of a Falco rules file.
+type List struct {
+	Name     string                  `yaml:"list"`
+	Items    []string                `yaml:"items"`
+	Append   *bool                   `yaml:"append,omitempty"`
+	Override map[string]OverrideMode `yaml:"override,omitempty"`
+}
+
+// Macro is a macro item of a Falco rules file.
+type Macro struct {
+	Name      string                  `yaml:"macro"`
+	Condition string                  `yaml:"condition,omitempty"`
+	Append    *bool                   `yaml:"append,omitempty"`
+	Override  map[string]OverrideMode `yaml:"override,omitempty"`
+}
+
+// Exception is an exception defined in a rule of a Falco rules file.
+// Each value is a tuple of values to be compared with each of the Fields
+// with the operator at the same position in Comps.
+//
+// Single-field exceptions can be expressed in the scalar form by setting
+// Field, Comp, and Items instead (e.g. `fields: proc.name` and
+// `values: [a, b]`), in which each item is compared with the field.
+// The two forms can't be mixed.
+type Exception struct {
+	Name   string     `yaml:"name,omitempty"`
+	Fields []string   `yaml:"fields,omitempty,flow"`
+	Comps  []string   `yaml:"comps,omitempty,flow"`
+	Values [][]string `yaml:"values,omitempty"`
+	//
+	// Field, Comp, and Items are the scalar form of Fields, Comps,
+	// and Values
+	Field string   `yaml:"-"`
+	Comp  string   `yaml:"-"`
+	Items []string `yaml:"-"`
+}
+
+// SingleFieldException returns an exception in the scalar form, comparing
+// a single field with each of the given values with the given operator.
+func SingleFieldException(name, field, comp string, values ...string) Exception {
+	return Exception{Name: name, Field: field, Comp: comp, Items: values}
+}
+
+// MarshalYAML encodes the exception in either the tuple or the scalar form.
+func (e Exception) MarshalYAML() (interface{}, error) {
+	if len(e.Field) == 0 && len(e.Comp) == 0 && len(e.Items) == 0 {
+		// note: the alias type has no methods, thus avoiding recursion
+		type tupleException Exception
+		return tupleException(e), nil
+	}
+	if len(e.Fields) > 0 || len(e.Comps) > 0 || len(e.Values) > 0 {
+		return nil, fmt.Errorf("exception '%s' mixes the scalar and the tuple forms", e.Name)
+	}
+	return struct {
+		Name   string   `yaml:"name,omitempty"`
+		Fields string   `yaml:"fields,omitempty"`
+		Comps  string   `yaml:"comps,omitempty"`
+		Values []string `yaml:"values,omitempty,flow"`
+	}{Name: e.Name, Fields: e.Field, Comps: e.Comp, Values: e.Items}, nil
+}
+
+// Rule is a rule item of a Falco rules file.
+type Rule struct {
+	Name                string                  `yaml:"rule"`
+	Desc                string                  `yaml:"desc,omitempty"`
+	Condition           string                  `yaml:"condition,omitempty"`
+	Output              string                  `yaml:"output,omitempty"`
+	Priority            string                  `yaml:"priority,omitempty"`
+	Source              string                  `yaml:"source,omitempty"`
+	Tags                []string                `yaml:"tags,omitempty,flow"`
+	Enabled             *bool                   `yaml:"enabled,omitempty"`
+	Exceptions          []Exception             `yaml:"exceptions,omitempty"`
+	WarnEvttypes        *bool                   `yaml:"warn_evttypes,omitempty"`
+	SkipIfUnknownFilter *bool                   `yaml:"skip-if-unknown-filter,omitempty"`
+	Append              *bool                   `yaml:"append,omitempty"`
+	Override            map[string]OverrideMode `yaml:"override,omitempty"`
+}
+
+func (RequiredEngineVersion) item()  {}
+func (RequiredPluginVersions) item() {}
+func (List) item()                   {}
+func (Macro) item()                  {}
+func (Rule) item()                   {}
+
+// AppendList returns a list item appending the given items to
+// a previously-defined list with the same name.
+func AppendList(name string, items ...string) *List {
+	return &List{
+		Name:     name,
+		Items:    items,
+		Override: map[string]OverrideMode{"items": OverrideAppend},
+	}
+}
+
+// AppendMacro returns a macro item appending the given condition to
+// the one of a previously-defined macro with the same name.
+func AppendMacro(name, condition string) *Macro {
+	return &Macro{
+		Name:      name,
+		Condition: condition,
+		Override:  map[string]OverrideMode{"condition": OverrideAppend},
+	}
+}
+
+// Overriding returns a copy of the rule that overrides the given fields
+// of a previously-defined rule with the same name with a given mode.
+// The fields are named as they are in a rules file (e.g. "condition").
+func (r Rule) Overriding(mode OverrideMode, fields ...string) *Rule {
+	r.Override = withOverrides(r.Override, mode, fields...)
+	return &r
+}
+
+// Overriding returns a copy of the macro that overrides the given fields
+// of a previously-defined macro with the same name with a given mode.
+func (m Macro) Overriding(mode OverrideMode, fields ...string) *Macro {
+	m.Override = withOverrides(m.Override, mode, fields...)
+	return &m
+}
+
+// Overriding returns a copy of the list that overrides the given fields
+// of a previously-defined list with the same name with a given mode.
+func (l List) Overriding(mode OverrideMode, fields ...string) *List {
+	l.Override = withOverrides(l.Override, mode, fields...)
+	return &l
+}
+
+func withOverrides(m map[string]OverrideMode, mode OverrideMode, fields ...string) map[string]OverrideMode {
+	res := make(map[string]OverrideMode)
+	for k, v := range m {
+		res[k] = v
+	}
+	for _, f := range fields {
+		res[f] = mode
+	}
+	return res
+}
+
+// File represents a Falco rules file composed of an ordered list of items.
+type File struct {
+	Items []Item
+}
+
+// NewFile creates a new rules file with the given items.
+func NewFile(items ...Item) *File {
+	return &File{Items: items}
+}
+
+// Add appends the given items at the end of the rules file. Items with the
+// same name of previously-added ones follow Falco's semantics for appending
+// and overriding, depending on their Append and Override fields.
+func (f *File) Add(items ...Item) *File {
+	f.Items = append(f.Items, items...)
+	return f
+}
+
+// YAML serializes the rules file in the YAML format.
+func (f *File) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	items := f.Items
+	if items == nil {
+		items = []Item{}
+	}
+	if err := encoder.Encode(items); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// File serializes the rules file into a file accessor with the given name,
+// which can be used with falco.WithRules.
+func (f *File) File(name string) (run.FileAccessor, error) {
+	data, err := f.YAML()
+	if err != nil {
+		return nil, err
+	}
+	return run.NewBytesFileAccessor(name, data), nil
+}
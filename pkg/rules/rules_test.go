@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package rules
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileRender(t *testing.T) {
+	enabled := false
+	rule := Rule{
+		Name:      "open_from_cat",
+		Desc:      "A process named cat does an open",
+		Condition: "evt.type=open and is_cat",
+		Output:    "An open was seen (command=%proc.cmdline)",
+		Priority:  "WARNING",
+		Tags:      []string{"a", "b"},
+		Exceptions: []Exception{
+			{Name: "ex1", Fields: []string{"proc.name", "fd.filename"}, Comps: []string{"=", "="}},
+			SingleFieldException("ex2", "proc.name", "in", "a", "b"),
+		},
+	}
+	file := NewFile(
+		&RequiredEngineVersion{Version: "0.11.0"},
+		&RequiredPluginVersions{Plugins: []PluginVersion{{Name: "json", Version: "0.1.0"}}},
+		&List{Name: "cat_binaries", Items: []string{"cat"}},
+		&Macro{Name: "is_cat", Condition: "proc.name in (cat_binaries)"},
+		&rule,
+	).Add(
+		AppendList("cat_binaries", "bat"),
+		AppendMacro("is_cat", "or proc.name=bat"),
+		Rule{Name: "open_from_cat", Enabled: &enabled}.Overriding(OverrideReplace, "enabled"),
+	)
+
+	f, err := file.File("rules.yaml")
+	require.Nil(t, err)
+	require.Equal(t, "rules.yaml", f.Name())
+	content, err := f.Content()
+	require.Nil(t, err)
+	require.Equal(t, `- required_engine_version: 0.11.0
+- required_plugin_versions:
+    - name: json
+      version: 0.1.0
+- list: cat_binaries
+  items:
+    - cat
+- macro: is_cat
+  condition: proc.name in (cat_binaries)
+- rule: open_from_cat
+  desc: A process named cat does an open
+  condition: evt.type=open and is_cat
+  output: An open was seen (command=%proc.cmdline)
+  priority: WARNING
+  tags: [a, b]
+  exceptions:
+    - name: ex1
+      fields: [proc.name, fd.filename]
+      comps: [=, =]
+    - name: ex2
+      fields: proc.name
+      comps: in
+      values: [a, b]
+- list: cat_binaries
+  items:
+    - bat
+  override:
+    items: append
+- macro: is_cat
+  condition: or proc.name=bat
+  override:
+    condition: append
+- rule: open_from_cat
+  enabled: false
+  override:
+    enabled: replace
+`, string(content))
+}
+
+func TestOverridingCopy(t *testing.T) {
+	base := Rule{Name: "r", Override: map[string]OverrideMode{"output": OverrideReplace}}
+	res := base.Overriding(OverrideAppend, "condition", "exceptions")
+	require.Len(t, base.Override, 1)
+	require.Equal(t, map[string]OverrideMode{
+		"output":     OverrideReplace,
+		"condition":  OverrideAppend,
+		"exceptions": OverrideAppend,
+	}, res.Override)
+}
+
+func TestExceptionMixedForms(t *testing.T) {
+	ex := SingleFieldException("ex", "proc.name", "in", "a")
+	ex.Fields = []string{"fd.name"}
+	_, err := NewFile(&Rule{Name: "r", Exceptions: []Exception{ex}}).File("rules.yaml")
+	require.Error(t, err)
+}
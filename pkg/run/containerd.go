@@ -0,0 +1,370 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+)
+
+// ContainerdRunnerOptions are the options of a runner
+// based on containerd
+type ContainerdRunnerOptions struct {
+	Privileged bool
+	Binds      []string
+	//
+	// Namespace is the containerd namespace in which containers are created.
+	// Uses nerdctl's default if empty.
+	Namespace string
+	//
+	// Address is the address of the containerd socket.
+	// Uses nerdctl's default if empty.
+	Address string
+	//
+	// Nerdctl is the path to the nerdctl executable used to interact
+	// with containerd. Defaults to "nerdctl" if empty.
+	Nerdctl string
+	//
+	// CapAdd and CapDrop are the kernel capabilities added to and dropped
+	// from the container (e.g. "SYS_ADMIN", "ALL")
+	CapAdd  []string
+	CapDrop []string
+	//
+	// NetworkMode is the network mode of the container (e.g. "host", "none")
+	NetworkMode string
+	//
+	// Memory is the memory limit of the container in bytes, and CPUs is the
+	// amount of CPUs it can use (e.g. 1.5). Zero values mean no limit.
+	Memory int64
+	CPUs   float64
+	//
+	// User is the user (and optionally the group) running the
+	// executable in the container (e.g. "1000:1000")
+	User string
+	//
+	// ReadonlyRootfs mounts the container's root filesystem as read-only
+	ReadonlyRootfs bool
+	//
+	// Tmpfs is a map from paths of tmpfs mounts in the container to their
+	// mount options (e.g. "rw,size=64m")
+	Tmpfs map[string]string
+	//
+	// ExtraHosts is a list of "hostname:IP" mappings added to /etc/hosts
+	ExtraHosts []string
+	//
+	// Labels is a set of labels attached to the container
+	Labels map[string]string
+}
+
+// createArgs returns the arguments of the nerdctl create command
+// for the options
+func (o *ContainerdRunnerOptions) createArgs() []string {
+	var res []string
+	if o.Privileged {
+		res = append(res, "--privileged")
+	}
+	for _, b := range o.Binds {
+		res = append(res, "--volume", b)
+	}
+	for _, c := range o.CapAdd {
+		res = append(res, "--cap-add", c)
+	}
+	for _, c := range o.CapDrop {
+		res = append(res, "--cap-drop", c)
+	}
+	if len(o.NetworkMode) > 0 {
+		res = append(res, "--network", o.NetworkMode)
+	}
+	if o.Memory > 0 {
+		res = append(res, "--memory", strconv.FormatInt(o.Memory, 10))
+	}
+	if o.CPUs > 0 {
+		res = append(res, "--cpus", strconv.FormatFloat(o.CPUs, 'f', -1, 64))
+	}
+	if len(o.User) > 0 {
+		res = append(res, "--user", o.User)
+	}
+	if o.ReadonlyRootfs {
+		res = append(res, "--read-only")
+	}
+	for _, p := range sortedKeys(o.Tmpfs) {
+		tmpfs := p
+		if len(o.Tmpfs[p]) > 0 {
+			tmpfs += ":" + o.Tmpfs[p]
+		}
+		res = append(res, "--tmpfs", tmpfs)
+	}
+	for _, h := range o.ExtraHosts {
+		res = append(res, "--add-host", h)
+	}
+	for _, k := range sortedKeys(o.Labels) {
+		res = append(res, "--label", fmt.Sprintf(`%s=%s`, k, o.Labels[k]))
+	}
+	return res
+}
+
+type containerdRunner struct {
+	m          sync.Mutex
+	image      string
+	entrypoint string
+	options    ContainerdRunnerOptions
+}
+
+// NewContainerdRunner returns a runner that runs a container image with
+// containerd, by using the nerdctl command line tool.
+func NewContainerdRunner(image, entrypoint string, options *ContainerdRunnerOptions) (Runner, error) {
+	res := &containerdRunner{image: image, entrypoint: entrypoint}
+	if options != nil {
+		res.options = *options
+	}
+	if len(res.options.Nerdctl) == 0 {
+		res.options.Nerdctl = "nerdctl"
+	}
+
+	// attempt pulling the image
+	logrus.WithField("image", res.image).Debugf("pulling containerd image")
+	if _, err := res.nerdctl(context.Background(), "pull", "--quiet", res.image); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *containerdRunner) WorkDir() string {
+	// note: the working directory is the root dir itself, because
+	// the executable will run inside a container
+	return "/"
+}
+
+func (c *containerdRunner) Run(ctx context.Context, options ...RunnerOption) error {
+	p, err := c.Start(ctx, options...)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+func (c *containerdRunner) Start(ctx context.Context, options ...RunnerOption) (Process, error) {
+	c.m.Lock()
+	opts := buildRunOptions(options...)
+	res := &containerdProcess{ctx: ctx, runner: c}
+	res.execProcess = &execProcess{
+		processStreams: newProcessStreams(),
+		release: func() {
+			if err := res.release(); err != nil {
+				logrus.WithField("containerID", res.containerID).Errorf("can't release containerd container: %s", err.Error())
+			}
+		},
+		// note: nerdctl exits with the same exit code of the container,
+		// unless it gets terminated by a signal itself
+		exitCodeError: func(err error) error {
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+				return containerExitCodeError(exitErr.ExitCode(), false)
+			}
+			return processExitCodeError(err)
+		},
+	}
+
+	// create a container and copy all loaded files into it
+	var err error
+	res.containerID, err = c.createContainer(ctx, opts)
+	if err == nil {
+		err = c.copyFiles(ctx, res.containerID, opts.files)
+	}
+	if err != nil {
+		res.close()
+		return nil, multierr.Append(err, res.release())
+	}
+
+	// start the container and attach to its outputs
+	res.collect = func() {
+		res.collectFiles(opts, c.WorkDir(), func(path string) ([]byte, error) {
+			return c.readFile(ctx, res.containerID, path)
+		})
+	}
+	logrus.WithField("containerID", res.containerID).Debugf("starting containerd container")
+	res.cmd = c.command(ctx, "start", "--attach", res.containerID)
+	res.cmd.Stdout, res.cmd.Stderr = res.teeWriters(opts)
+	if err := res.cmd.Start(); err != nil {
+		res.close()
+		return nil, multierr.Append(err, res.release())
+	}
+	return res, nil
+}
+
+// command returns a nerdctl command with the given args
+func (c *containerdRunner) command(ctx context.Context, args ...string) *exec.Cmd {
+	var globalArgs []string
+	if len(c.options.Namespace) > 0 {
+		globalArgs = append(globalArgs, "--namespace", c.options.Namespace)
+	}
+	if len(c.options.Address) > 0 {
+		globalArgs = append(globalArgs, "--address", c.options.Address)
+	}
+	return exec.CommandContext(ctx, c.options.Nerdctl, append(globalArgs, args...)...)
+}
+
+// nerdctl runs a nerdctl command with the given args and returns its stdout
+func (c *containerdRunner) nerdctl(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := c.command(ctx, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("nerdctl %s failed: %s (%s)", args[0], err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (c *containerdRunner) createContainer(ctx context.Context, opts *runOpts) (string, error) {
+	args := append([]string{"create", "--entrypoint", c.entrypoint}, c.options.createArgs()...)
+	for _, k := range sortedKeys(opts.envVars) {
+		args = append(args, "--env", fmt.Sprintf(`%s=%s`, k, opts.envVars[k]))
+	}
+	// note: like with Docker, the ports are published on the loopback
+	// interface of the host, unless the container uses the host's network
+	if c.options.NetworkMode != "host" {
+		for _, p := range opts.ports {
+			args = append(args, "--publish", fmt.Sprintf("127.0.0.1:%d:%d", p, p))
+		}
+	}
+	args = append(append(args, c.image), opts.args...)
+	logrus.WithField("image", c.image).WithField("privileged", c.options.Privileged).Debugf("creating new containerd container")
+	return c.nerdctl(ctx, args...)
+}
+
+func (c *containerdRunner) removeContainer(ctx context.Context, containerID string) error {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+	logrus.WithField("containerID", containerID).Debugf("removing containerd container")
+	_, err := c.nerdctl(ctx, "rm", "--force", containerID)
+	return err
+}
+
+// copyFiles copies the files into the container by writing them in a
+// temporary directory on the host that mirrors the container's filesystem
+func (c *containerdRunner) copyFiles(ctx context.Context, containerID string, files []FileAccessor) (err error) {
+	if len(files) == 0 {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", execRunnerWorkDirPrefix)
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, os.RemoveAll(dir)) }()
+	if err := writeFileTree(dir, c.WorkDir(), files); err != nil {
+		return err
+	}
+
+	logrus.WithField("containerID", containerID).Debugf("copying files")
+	_, err = c.nerdctl(ctx, "cp", dir+"/.", containerID+":/")
+	return err
+}
+
+// readFile reads the content of a file from a container by copying
+// it into a temporary directory on the host
+func (c *containerdRunner) readFile(ctx context.Context, containerID, filePath string) (res []byte, err error) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+
+	dir, err := os.MkdirTemp("", execRunnerWorkDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = multierr.Append(err, os.RemoveAll(dir)) }()
+
+	hostPath := filepath.Join(dir, filepath.Base(filePath))
+	if _, err := c.nerdctl(ctx, "cp", containerID+":"+filePath, hostPath); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(hostPath)
+}
+
+// writeFileTree writes the files into a directory, at the path they
+// would have in a filesystem rooted at it
+func writeFileTree(dir, baseDir string, files []FileAccessor) error {
+	for _, f := range files {
+		// if file's name is a relative path, copy it in the base dir.
+		// if file's name is an absolute path, it is copied as-is
+		fileName := f.Name()
+		if !path.IsAbs(fileName) {
+			fileName = path.Join(baseDir, fileName)
+		}
+		content, err := f.Content()
+		if err != nil {
+			return err
+		}
+		fileName = filepath.Join(dir, filepath.Clean("/"+fileName))
+		if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(fileName, content, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// containerdProcess is a process running as the main process of a
+// containerd container, attached through nerdctl
+type containerdProcess struct {
+	*execProcess
+	ctx         context.Context
+	runner      *containerdRunner
+	containerID string
+}
+
+// release removes the container and makes the runner
+// available for other executions
+func (p *containerdProcess) release() (err error) {
+	defer p.runner.m.Unlock()
+	if len(p.containerID) > 0 {
+		err = p.runner.removeContainer(p.ctx, p.containerID)
+	}
+	return err
+}
+
+func (p *containerdProcess) Signal(sig os.Signal) error {
+	logrus.WithField("containerID", p.containerID).WithField("signal", sig).Debugf("signaling containerd container")
+	_, err := p.runner.nerdctl(context.Background(), "kill", "--signal", dockerSignal(sig), p.containerID)
+	return err
+}
+
+func (p *containerdProcess) Pid() int {
+	out, err := p.runner.nerdctl(context.Background(), "inspect", "--format", "{{.State.Pid}}", p.containerID)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(out)
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileTree(t *testing.T) {
+	dir := t.TempDir()
+	require.Nil(t, writeFileTree(dir, "/", []FileAccessor{
+		NewStringFileAccessor("some-file", "hello"),
+		NewStringFileAccessor("testdir/other-file", "world"),
+		NewStringFileAccessor("/etc/abs-file", "!"),
+	}))
+	for name, content := range map[string]string{
+		"some-file":          "hello",
+		"testdir/other-file": "world",
+		"etc/abs-file":       "!",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.Nil(t, err)
+		require.Equal(t, content, string(data))
+	}
+}
+
+func TestContainerdCreateArgs(t *testing.T) {
+	opts := &ContainerdRunnerOptions{
+		Privileged:     true,
+		Binds:          []string{"/a:/b"},
+		CapAdd:         []string{"SYS_ADMIN"},
+		CapDrop:        []string{"ALL"},
+		NetworkMode:    "none",
+		Memory:         1024,
+		CPUs:           1.5,
+		User:           "1000:1000",
+		ReadonlyRootfs: true,
+		Tmpfs:          map[string]string{"/tmp": "rw,size=64m", "/run": ""},
+		ExtraHosts:     []string{"host:127.0.0.1"},
+		Labels:         map[string]string{"b": "2", "a": "1"},
+	}
+	require.Equal(t, []string{
+		"--privileged",
+		"--volume", "/a:/b",
+		"--cap-add", "SYS_ADMIN",
+		"--cap-drop", "ALL",
+		"--network", "none",
+		"--memory", "1024",
+		"--cpus", "1.5",
+		"--user", "1000:1000",
+		"--read-only",
+		"--tmpfs", "/run",
+		"--tmpfs", "/tmp:rw,size=64m",
+		"--add-host", "host:127.0.0.1",
+		"--label", "a=1",
+		"--label", "b=2",
+	}, opts.createArgs())
+	require.Empty(t, (&ContainerdRunnerOptions{}).createArgs())
+}
@@ -25,8 +25,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -34,13 +39,73 @@ import (
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
 	"github.com/sirupsen/logrus"
 	"go.uber.org/multierr"
 )
 
+// dockerReadonlyWorkDir is the working directory of the containers
+// with a read-only root filesystem, in which a volume is mounted
+const dockerReadonlyWorkDir = "/falcosecurity-testing"
+
+// DockerRunnerOptions are the options of the container in which
+// runners based on Docker run the executable
 type DockerRunnerOptions struct {
 	Privileged bool
 	Binds      []string
+	//
+	// CapAdd and CapDrop are the kernel capabilities added to and dropped
+	// from the container (e.g. "SYS_ADMIN", "ALL")
+	CapAdd  []string
+	CapDrop []string
+	//
+	// NetworkMode is the network mode of the container (e.g. "host", "none")
+	NetworkMode string
+	//
+	// Memory is the memory limit of the container in bytes, and CPUs is the
+	// amount of CPUs it can use (e.g. 1.5). Zero values mean no limit.
+	Memory int64
+	CPUs   float64
+	//
+	// User is the user (and optionally the group) running the
+	// executable in the container (e.g. "1000:1000")
+	User string
+	//
+	// ReadonlyRootfs mounts the container's root filesystem as read-only.
+	// If this is set, the working directory of the container is a writable
+	// anonymous volume in which the files with a relative path are copied,
+	// and the files with an absolute path can only be copied if they are
+	// inside the working directory or another writable mount.
+	ReadonlyRootfs bool
+	//
+	// Tmpfs is a map from paths of tmpfs mounts in the container to their
+	// mount options (e.g. "rw,size=64m")
+	Tmpfs map[string]string
+	//
+	// ExtraHosts is a list of "hostname:IP" mappings added to /etc/hosts
+	ExtraHosts []string
+	//
+	// Labels is a set of labels attached to the container
+	Labels map[string]string
+}
+
+// hostConfig returns the host configuration of the containers
+// created with the options
+func (o *DockerRunnerOptions) hostConfig() *container.HostConfig {
+	return &container.HostConfig{
+		Privileged:     o.Privileged,
+		Binds:          o.Binds,
+		CapAdd:         strslice.StrSlice(o.CapAdd),
+		CapDrop:        strslice.StrSlice(o.CapDrop),
+		NetworkMode:    container.NetworkMode(o.NetworkMode),
+		ReadonlyRootfs: o.ReadonlyRootfs,
+		Tmpfs:          o.Tmpfs,
+		ExtraHosts:     o.ExtraHosts,
+		Resources: container.Resources{
+			Memory:   o.Memory,
+			NanoCPUs: int64(o.CPUs * 1e9),
+		},
+	}
 }
 
 type dockerRunner struct {
@@ -48,31 +113,23 @@ type dockerRunner struct {
 	image      string
 	entrypoint string
 	options    DockerRunnerOptions
+	newClient  func() (*client.Client, error)
 }
 
 // NewDockerRunner returns a runner that runs a container image with Docker
 func NewDockerRunner(image, entrypoint string, options *DockerRunnerOptions) (Runner, error) {
-	res := &dockerRunner{image: image, entrypoint: entrypoint}
+	return newDockerRunner(image, entrypoint, options, newDockerClient)
+}
+
+func newDockerRunner(image, entrypoint string, options *DockerRunnerOptions, newClient func() (*client.Client, error)) (Runner, error) {
+	res := &dockerRunner{image: image, entrypoint: entrypoint, newClient: newClient}
 	if options != nil {
 		res.options = *options
 	}
 
 	// attempt pulling the image
-	err := res.withClient(context.Background(), func(cli *client.Client) error {
-		logrus.WithField("image", res.image).Debugf("pulling docker image")
-		reader, err := cli.ImagePull(context.Background(), res.image, types.ImagePullOptions{})
-		if err != nil {
-			return err
-		}
-
-		// consume output and wait up until pulling is finished
-		scanner := bufio.NewScanner(reader)
-		scanner.Split(bufio.ScanLines)
-		for scanner.Scan() {
-			logrus.Debugf(scanner.Text())
-		}
-		defer reader.Close()
-		return scanner.Err()
+	err := withDockerClient(res.newClient, func(cli *client.Client) error {
+		return pullDockerImage(context.Background(), cli, res.image)
 	})
 	if err != nil {
 		return nil, err
@@ -84,58 +141,63 @@ func (d *dockerRunner) WorkDir() string {
 	// note: this is constant after construction and does not need
 	// mutex protection
 	// note: the working directory is the root dir itself, because
-	// the executable will run inside a container
+	// the executable will run inside a container, unless the root dir
+	// is read-only and files need to be copied into a volume
 	// todo(jasondellaluce): figure out if root dir can cause issues
+	if d.options.ReadonlyRootfs {
+		return dockerReadonlyWorkDir
+	}
 	return "/"
 }
 
-func (d *dockerRunner) Run(ctx context.Context, options ...RunnerOption) (retErr error) {
+func (d *dockerRunner) Run(ctx context.Context, options ...RunnerOption) error {
+	p, err := d.Start(ctx, options...)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+func (d *dockerRunner) Start(ctx context.Context, options ...RunnerOption) (Process, error) {
 	d.m.Lock()
-	defer d.m.Unlock()
 	opts := buildRunOptions(options...)
-	return d.withClient(ctx, func(cli *client.Client) (err error) {
-		// create a container
-		var containerID string
-		containerID, err = d.createContainer(ctx, cli, opts)
-		if err != nil {
-			return err
-		}
-		defer func() { err = multierr.Append(err, d.removeContainer(cli, containerID)) }()
-
-		// copy all loaded files into the container
-		err = d.copyFilesArchive(ctx, cli, containerID, opts.files)
-		if err != nil {
-			return err
-		}
-
-		// attach to container
-		logrus.WithField("containerID", containerID).Debugf("attaching to docker container")
-		hr, err := cli.ContainerAttach(ctx, containerID, types.ContainerAttachOptions{
-			Stdout: true,
-			Stderr: true,
-			Stream: true,
-		})
-		if err != nil {
-			return err
-		}
-		defer hr.Close()
-
-		// start the container
-		err = d.startContainer(ctx, cli, containerID)
-		if err != nil {
-			return err
+	cli, err := d.newClient()
+	if err != nil {
+		d.m.Unlock()
+		return nil, err
+	}
+	res := newDockerProcess(ctx, cli, d.WorkDir())
+	res.release = func() error {
+		defer d.m.Unlock()
+		var err error
+		if len(res.containerID) > 0 {
+			err = removeDockerContainer(ctx, cli, res.containerID)
 		}
-		defer func() { err = multierr.Append(err, d.stopContainer(cli, containerID)) }()
+		return multierr.Append(err, cli.Close())
+	}
 
-		// pipe and collect all container outputs
-		_, err = stdcopy.StdCopy(opts.stdout, opts.stderr, hr.Reader)
-		return err
-	})
+	// create a container and copy all loaded files into it
+	res.containerID, err = d.createContainer(ctx, cli, opts)
+	if err == nil {
+		err = copyDockerFiles(ctx, cli, res.containerID, d.WorkDir(), opts.files)
+	}
+	if err == nil {
+		err = res.start(ctx, opts)
+	}
+	if err != nil {
+		res.close()
+		return nil, multierr.Append(err, res.shutdown())
+	}
+	return res, nil
 }
 
-func (d *dockerRunner) withClient(ctx context.Context, do func(*client.Client) error) error {
+func newDockerClient() (*client.Client, error) {
 	logrus.Debugf("creating new docker client")
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	return client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+}
+
+func withDockerClient(newClient func() (*client.Client, error), do func(*client.Client) error) error {
+	cli, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -143,6 +205,23 @@ func (d *dockerRunner) withClient(ctx context.Context, do func(*client.Client) e
 	return do(cli)
 }
 
+func pullDockerImage(ctx context.Context, cli *client.Client, image string) error {
+	logrus.WithField("image", image).Debugf("pulling docker image")
+	reader, err := cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	// consume output and wait up until pulling is finished
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+	for scanner.Scan() {
+		logrus.Debugf(scanner.Text())
+	}
+	return scanner.Err()
+}
+
 func (d *dockerRunner) createContainer(ctx context.Context, cli *client.Client, opts *runOpts) (id string, err error) {
 	var resp container.CreateResponse
 	var env []string
@@ -150,61 +229,280 @@ func (d *dockerRunner) createContainer(ctx context.Context, cli *client.Client,
 		env = append(env, fmt.Sprintf(`%s=%s`, k, v))
 	}
 
+	config := &container.Config{
+		Image:      d.image,
+		Entrypoint: strslice.StrSlice(append([]string{d.entrypoint}, opts.args...)),
+		Env:        env,
+		User:       d.options.User,
+		Labels:     d.options.Labels,
+	}
+	if d.options.ReadonlyRootfs {
+		config.Volumes = map[string]struct{}{d.WorkDir(): {}}
+	}
+	hostConfig := d.options.hostConfig()
+	publishDockerPorts(config, hostConfig, opts.ports)
+
 	logrus.WithField("image", d.image).WithField("privileged", d.options.Privileged).Debugf("creating new docker container")
-	resp, err = cli.ContainerCreate(
-		ctx,
-		&container.Config{
-			Image:      d.image,
-			Entrypoint: strslice.StrSlice(append([]string{d.entrypoint}, opts.args...)),
-			Env:        env,
-		},
-		&container.HostConfig{
-			Privileged: d.options.Privileged,
-			Binds:      d.options.Binds,
-		},
-		nil, nil, "")
+	resp, err = cli.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
 	if err != nil {
 		return "", err
 	}
 	return resp.ID, err
 }
 
-func (d *dockerRunner) removeContainer(cli *client.Client, containerID string) error {
-	// note: the context's deadline may be done, but we still want to wait
-	ctx := context.Background()
+// publishDockerPorts publishes the given TCP ports of a container on the
+// loopback interface of the host with the same port numbers. This is not
+// needed if the container uses the host's network.
+func publishDockerPorts(config *container.Config, hostConfig *container.HostConfig, ports []int) {
+	if len(ports) == 0 || hostConfig.NetworkMode.IsHost() {
+		return
+	}
+	config.ExposedPorts = make(nat.PortSet)
+	hostConfig.PortBindings = make(nat.PortMap)
+	for _, p := range ports {
+		port := nat.Port(fmt.Sprintf("%d/tcp", p))
+		config.ExposedPorts[port] = struct{}{}
+		hostConfig.PortBindings[port] = []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: strconv.Itoa(p)}}
+	}
+}
+
+func removeDockerContainer(ctx context.Context, cli *client.Client, containerID string) error {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
 	logrus.WithField("containerID", containerID).Debugf("removing docker container")
-	return cli.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true})
+	return cli.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
 }
 
-func (d *dockerRunner) startContainer(ctx context.Context, cli *client.Client, containerID string) error {
+func startDockerContainer(ctx context.Context, cli *client.Client, containerID string) error {
 	logrus.WithField("containerID", containerID).Debugf("starting docker container")
 	return cli.ContainerStart(ctx, containerID, types.ContainerStartOptions{})
 }
 
-func (d *dockerRunner) stopContainer(cli *client.Client, containerID string) error {
-	// note: the context's deadline may be done, but we still want to wait
-	ctx := context.Background()
+func stopDockerContainer(ctx context.Context, cli *client.Client, containerID string) error {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
 	logrus.WithField("containerID", containerID).Debugf("stopping docker container")
 	return cli.ContainerStop(ctx, containerID, container.StopOptions{})
 }
 
-func (d *dockerRunner) copyFilesArchive(ctx context.Context, cli *client.Client, containerID string, files []FileAccessor) error {
-	logrus.WithField("containerID", containerID).Debugf("creating files archive")
-	var buf bytes.Buffer
-	if err := d.tarFiles(d.WorkDir(), &buf, files...); err != nil {
+// dockerProcess is a process running as the main process of an
+// already-created docker container
+type dockerProcess struct {
+	processStreams
+	ctx         context.Context
+	cli         *client.Client
+	containerID string
+	workDir     string
+	opts        *runOpts
+	hijacked    *types.HijackedResponse
+	started     bool
+	done        chan struct{}
+	copyErr     error
+	watched     chan struct{}
+	cancelErr   error
+	release     func() error
+	once        sync.Once
+	err         error
+}
+
+func newDockerProcess(ctx context.Context, cli *client.Client, workDir string) *dockerProcess {
+	return &dockerProcess{
+		processStreams: newProcessStreams(),
+		ctx:            ctx,
+		cli:            cli,
+		workDir:        workDir,
+		done:           make(chan struct{}),
+		watched:        make(chan struct{}),
+	}
+}
+
+// start attaches to the process container and starts it
+func (p *dockerProcess) start(ctx context.Context, opts *runOpts) error {
+	p.opts = opts
+
+	// attach to container
+	logrus.WithField("containerID", p.containerID).Debugf("attaching to docker container")
+	hr, err := p.cli.ContainerAttach(ctx, p.containerID, types.ContainerAttachOptions{
+		Stdout: true,
+		Stderr: true,
+		Stream: true,
+	})
+	if err != nil {
+		return err
+	}
+	p.hijacked = &hr
+
+	// start the container
+	err = startDockerContainer(ctx, p.cli, p.containerID)
+	if err != nil {
+		return err
+	}
+	p.started = true
+
+	// pipe and collect all container outputs
+	stdout, stderr := p.teeWriters(opts)
+	go func() {
+		defer close(p.done)
+		_, p.copyErr = stdcopy.StdCopy(stdout, stderr, hr.Reader)
+	}()
+
+	// stop the container if the context gets cancelled before it exits
+	go func() {
+		defer close(p.watched)
+		select {
+		case <-ctx.Done():
+			p.cancelErr = multierr.Append(ctx.Err(), stopDockerContainer(ctx, p.cli, p.containerID))
+		case <-p.done:
+		}
+	}()
+	return nil
+}
+
+// exitCodeError waits for the container to stop running and returns
+// an *ExitCodeError describing its exit status, or nil if it succeeded
+func (p *dockerProcess) exitCodeError() error {
+	if !p.started {
+		return nil
+	}
+	ctx, cancel := cleanupContext(p.ctx)
+	defer cancel()
+	waitC, errC := p.cli.ContainerWait(ctx, p.containerID, container.WaitConditionNotRunning)
+	var code int
+	select {
+	case res := <-waitC:
+		if res.Error != nil {
+			return fmt.Errorf("can't wait for docker container: %s", res.Error.Message)
+		}
+		code = int(res.StatusCode)
+	case err := <-errC:
+		return err
+	}
+	info, err := p.cli.ContainerInspect(ctx, p.containerID)
+	if err != nil {
 		return err
 	}
+	return containerExitCodeError(code, info.State != nil && info.State.OOMKilled)
+}
+
+// shutdown stops the container and releases all the resources
+// related to the process
+func (p *dockerProcess) shutdown() (err error) {
+	if p.hijacked != nil {
+		p.hijacked.Close()
+	}
+	if p.started {
+		err = stopDockerContainer(p.ctx, p.cli, p.containerID)
+	}
+	if p.release != nil {
+		err = multierr.Append(err, p.release())
+	}
+	return err
+}
 
-	logrus.WithField("containerID", containerID).Debugf("copying files archive")
-	return cli.CopyToContainer(ctx,
-		containerID,
-		d.WorkDir(),
-		bytes.NewReader(buf.Bytes()),
-		types.CopyToContainerOptions{AllowOverwriteDirWithFile: true},
-	)
+func (p *dockerProcess) Wait() error {
+	p.once.Do(func() {
+		<-p.done
+		<-p.watched
+		p.close()
+		exitErr := p.exitCodeError()
+		if p.started {
+			p.collectFiles(p.opts, p.workDir, func(path string) ([]byte, error) {
+				ctx, cancel := cleanupContext(p.ctx)
+				defer cancel()
+				return readDockerFile(ctx, p.cli, p.containerID, path)
+			})
+		}
+		p.err = multierr.Combine(p.cancelErr, p.copyErr, exitErr, p.shutdown())
+	})
+	return p.err
+}
+
+func (p *dockerProcess) Signal(sig os.Signal) error {
+	logrus.WithField("containerID", p.containerID).WithField("signal", sig).Debugf("signaling docker container")
+	return p.cli.ContainerKill(context.Background(), p.containerID, dockerSignal(sig))
+}
+
+func (p *dockerProcess) Pid() int {
+	info, err := p.cli.ContainerInspect(context.Background(), p.containerID)
+	if err != nil || info.State == nil {
+		return 0
+	}
+	return info.State.Pid
+}
+
+// dockerSignal converts a signal in the format accepted by the Docker API
+func dockerSignal(sig os.Signal) string {
+	if s, ok := sig.(syscall.Signal); ok {
+		return strconv.Itoa(int(s))
+	}
+	return sig.String()
+}
+
+// copyDockerFiles copies the files into a container, by resolving the
+// ones with a relative path from the given dir
+func copyDockerFiles(ctx context.Context, cli *client.Client, containerID, dir string, files []FileAccessor) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	// note: the files inside the given dir are extracted from it rather
+	// than from the root, so that they can be copied into a volume even
+	// if the container's root filesystem is read-only
+	var inDir, outDir []FileAccessor
+	for _, f := range files {
+		if !path.IsAbs(f.Name()) {
+			inDir = append(inDir, f)
+		} else if rel, err := filepath.Rel(dir, f.Name()); err == nil && !strings.HasPrefix(rel, "..") {
+			inDir = append(inDir, &renamedFileAccessor{FileAccessor: f, name: rel})
+		} else {
+			outDir = append(outDir, f)
+		}
+	}
+	for _, c := range []struct {
+		dir   string
+		files []FileAccessor
+	}{{dir, inDir}, {"/", outDir}} {
+		if len(c.files) == 0 {
+			continue
+		}
+		logrus.WithField("containerID", containerID).Debugf("creating files archive")
+		var buf bytes.Buffer
+		if err := tarFiles(".", &buf, c.files...); err != nil {
+			return err
+		}
+		logrus.WithField("containerID", containerID).WithField("dir", c.dir).Debugf("copying files archive")
+		err := cli.CopyToContainer(ctx,
+			containerID,
+			c.dir,
+			bytes.NewReader(buf.Bytes()),
+			types.CopyToContainerOptions{AllowOverwriteDirWithFile: true},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDockerFile reads the content of a regular file from a container
+func readDockerFile(ctx context.Context, cli *client.Client, containerID, filePath string) ([]byte, error) {
+	reader, _, err := cli.CopyFromContainer(ctx, containerID, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	tr := tar.NewReader(reader)
+	header, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil, fmt.Errorf("'%s' is not a regular file", filePath)
+	}
+	return io.ReadAll(tr)
 }
 
-func (d *dockerRunner) tarFiles(baseDir string, w io.Writer, files ...FileAccessor) (err error) {
+func tarFiles(baseDir string, w io.Writer, files ...FileAccessor) (err error) {
 	tw := tar.NewWriter(w)
 	defer func() {
 		err = multierr.Append(err, tw.Close())
@@ -0,0 +1,413 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+)
+
+const (
+	persistentDockerWorkDir = "/falcosecurity-testing"
+	persistentDockerPidFile = ".pid"
+)
+
+// PersistentRunner is a Runner that keeps its resources alive across
+// multiple executions, and that can run more than one execution
+// concurrently. Close must be called to release all its resources
+// once the runner is not used anymore.
+type PersistentRunner interface {
+	Runner
+	io.Closer
+}
+
+type persistentDockerRunner struct {
+	image       string
+	entrypoint  string
+	options     DockerRunnerOptions
+	cli         *client.Client
+	containerID string
+	runs        uint64
+	closeOnce   sync.Once
+	closeErr    error
+}
+
+// NewPersistentDockerRunner returns a runner that runs an executable inside
+// a single long-lived container of a given image with Docker. Each execution
+// is run with "docker exec" in its own subdirectory of the working dir, in
+// which all the files with a relative path are copied, so that concurrent
+// executions don't interfere. Files with an absolute path are shared between
+// all executions. The working dir path returned by WorkDir can be used in
+// the arguments, environment variables, and file paths of an execution, and
+// is resolved to the execution's own subdirectory when it starts. Since
+// the container is shared by all executions, ports can't be published for
+// a single one, so executions requesting ports with WithPorts fail to start
+// unless the container uses the host's network. The image must provide
+// "/bin/sh", "sleep", "kill", and "rm", which are used to manage the
+// container and the executions.
+func NewPersistentDockerRunner(image, entrypoint string, options *DockerRunnerOptions) (PersistentRunner, error) {
+	res := &persistentDockerRunner{image: image, entrypoint: entrypoint}
+	if options != nil {
+		res.options = *options
+	}
+
+	var err error
+	res.cli, err = newDockerClient()
+	if err != nil {
+		return nil, err
+	}
+	err = pullDockerImage(context.Background(), res.cli, res.image)
+	if err == nil {
+		err = res.createContainer(context.Background())
+	}
+	if err != nil {
+		return nil, multierr.Append(err, res.Close())
+	}
+	return res, nil
+}
+
+func (d *persistentDockerRunner) createContainer(ctx context.Context) error {
+	logrus.WithField("image", d.image).WithField("privileged", d.options.Privileged).Debugf("creating new persistent docker container")
+	// note: the working dir is an anonymous volume, so that run dirs
+	// can be created in it even if the root filesystem is read-only
+	config := &container.Config{
+		Image:      d.image,
+		Entrypoint: strslice.StrSlice{"sleep", "infinity"},
+		Labels:     d.options.Labels,
+		Volumes:    map[string]struct{}{d.WorkDir(): {}},
+	}
+	resp, err := d.cli.ContainerCreate(ctx, config, d.options.hostConfig(), nil, nil, "")
+	if err != nil {
+		return err
+	}
+	d.containerID = resp.ID
+	return startDockerContainer(ctx, d.cli, d.containerID)
+}
+
+// Close stops and removes the container and releases all the resources
+// of the runner. Calling Close more than once returns the same result.
+func (d *persistentDockerRunner) Close() error {
+	d.closeOnce.Do(func() {
+		if len(d.containerID) > 0 {
+			d.closeErr = removeDockerContainer(context.Background(), d.cli, d.containerID)
+		}
+		if d.cli != nil {
+			d.closeErr = multierr.Append(d.closeErr, d.cli.Close())
+		}
+	})
+	return d.closeErr
+}
+
+func (d *persistentDockerRunner) WorkDir() string {
+	// note: each execution runs in a subdirectory of this one, to which
+	// all the references to this path are resolved when starting it
+	return persistentDockerWorkDir
+}
+
+func (d *persistentDockerRunner) Run(ctx context.Context, options ...RunnerOption) error {
+	p, err := d.Start(ctx, options...)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+func (d *persistentDockerRunner) Start(ctx context.Context, options ...RunnerOption) (Process, error) {
+	res := &persistentDockerProcess{
+		processStreams: newProcessStreams(),
+		ctx:            ctx,
+		runner:         d,
+		runDir:         path.Join(d.WorkDir(), strconv.FormatUint(atomic.AddUint64(&d.runs, 1), 10)),
+		done:           make(chan struct{}),
+		watched:        make(chan struct{}),
+	}
+	opts := resolveRunDir(buildRunOptions(options...), d.WorkDir(), res.runDir)
+	if len(opts.ports) > 0 && !container.NetworkMode(d.options.NetworkMode).IsHost() {
+		return nil, fmt.Errorf("persistent docker runner can't publish ports %v without using the host's network", opts.ports)
+	}
+	if err := res.start(ctx, opts); err != nil {
+		res.close()
+		return nil, multierr.Append(err, res.release())
+	}
+	return res, nil
+}
+
+// resolveRunDir returns a copy of the given run options in which all
+// the references to the working dir are replaced with the run dir
+func resolveRunDir(opts *runOpts, workDir, runDir string) *runOpts {
+	rgx := regexp.MustCompile(regexp.QuoteMeta(workDir) + `\b`)
+	resolve := func(s string) string {
+		return rgx.ReplaceAllLiteralString(s, runDir)
+	}
+	res := *opts
+	res.args = nil
+	for _, a := range opts.args {
+		res.args = append(res.args, resolve(a))
+	}
+	res.outputFiles = nil
+	for _, f := range opts.outputFiles {
+		res.outputFiles = append(res.outputFiles, resolve(f))
+	}
+	res.envVars = make(map[string]string)
+	for k, v := range opts.envVars {
+		res.envVars[k] = resolve(v)
+	}
+	res.files = nil
+	for _, f := range opts.files {
+		if name := resolve(f.Name()); name != f.Name() {
+			f = &renamedFileAccessor{FileAccessor: f, name: name}
+		}
+		res.files = append(res.files, f)
+	}
+	return &res
+}
+
+// stageFiles creates the given run directory in the container and
+// copies all the given files into it
+func (d *persistentDockerRunner) stageFiles(ctx context.Context, runDir string, files []FileAccessor) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := tw.WriteHeader(&tar.Header{
+		Name:     path.Base(runDir) + "/",
+		ModTime:  time.Now(),
+		Mode:     int64(0777),
+		Typeflag: tar.TypeDir,
+	})
+	if err = multierr.Append(err, tw.Close()); err != nil {
+		return err
+	}
+	logrus.WithField("containerID", d.containerID).WithField("dir", runDir).Debugf("creating run directory")
+	err = d.cli.CopyToContainer(ctx, d.containerID, path.Dir(runDir), &buf, types.CopyToContainerOptions{})
+	if err != nil {
+		return err
+	}
+	return copyDockerFiles(ctx, d.cli, d.containerID, runDir, files)
+}
+
+// exec runs a command in the container, waits for it to finish,
+// and returns an error if it exits with a non-zero code
+func (d *persistentDockerRunner) exec(ctx context.Context, cmd ...string) error {
+	logrus.WithField("containerID", d.containerID).WithField("cmd", cmd).Debugf("executing command in docker container")
+	exec, err := d.cli.ContainerExecCreate(ctx, d.containerID, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+	hr, err := d.cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return err
+	}
+	defer hr.Close()
+	var stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(io.Discard, &stderr, hr.Reader); err != nil {
+		return err
+	}
+	code, err := d.execExitCode(ctx, exec.ID)
+	if err == nil && code != 0 {
+		err = fmt.Errorf("command %v exited with code %d: %s", cmd, code, stderr.String())
+	}
+	return err
+}
+
+// execExitCode waits for an exec instance to stop running and
+// returns its exit code
+func (d *persistentDockerRunner) execExitCode(ctx context.Context, execID string) (int, error) {
+	for {
+		info, err := d.cli.ContainerExecInspect(ctx, execID)
+		if err != nil {
+			return 0, err
+		}
+		if !info.Running {
+			return info.ExitCode, nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type persistentDockerProcess struct {
+	processStreams
+	ctx       context.Context
+	runner    *persistentDockerRunner
+	runDir    string
+	opts      *runOpts
+	staged    bool
+	execID    string
+	hijacked  *types.HijackedResponse
+	done      chan struct{}
+	copyErr   error
+	watched   chan struct{}
+	cancelErr error
+	once      sync.Once
+	err       error
+}
+
+func (p *persistentDockerProcess) start(ctx context.Context, opts *runOpts) error {
+	// create a run directory with all the loaded files
+	p.opts = opts
+	p.staged = true
+	if err := p.runner.stageFiles(ctx, p.runDir, opts.files); err != nil {
+		return err
+	}
+
+	// create and attach to an exec instance, which also starts it.
+	// note: the command is wrapped in a shell that stores its pid in
+	// the run directory before exec'ing, so that it can be signaled
+	var env []string
+	for k, v := range opts.envVars {
+		env = append(env, fmt.Sprintf(`%s=%s`, k, v))
+	}
+	cmd := append([]string{
+		"/bin/sh", "-c", `echo $$ > "$0" && exec "$@"`,
+		path.Join(p.runDir, persistentDockerPidFile),
+		p.runner.entrypoint,
+	}, opts.args...)
+	logrus.WithField("containerID", p.runner.containerID).WithField("dir", p.runDir).Debugf("executing in docker container")
+	exec, err := p.runner.cli.ContainerExecCreate(ctx, p.runner.containerID, types.ExecConfig{
+		Cmd:          cmd,
+		Env:          env,
+		User:         p.runner.options.User,
+		WorkingDir:   p.runDir,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+	p.execID = exec.ID
+	hr, err := p.runner.cli.ContainerExecAttach(ctx, p.execID, types.ExecStartCheck{})
+	if err != nil {
+		return err
+	}
+	p.hijacked = &hr
+
+	// pipe and collect all process outputs
+	stdout, stderr := p.teeWriters(opts)
+	go func() {
+		defer close(p.done)
+		_, p.copyErr = stdcopy.StdCopy(stdout, stderr, hr.Reader)
+	}()
+
+	// wait for the pid file to be written, so that the process can
+	// be signaled as soon as this returns
+	if err := p.waitPidFile(ctx); err != nil {
+		return err
+	}
+
+	// kill the process if the context gets cancelled before it exits
+	go func() {
+		defer close(p.watched)
+		select {
+		case <-ctx.Done():
+			p.cancelErr = multierr.Append(ctx.Err(), p.Signal(os.Kill))
+		case <-p.done:
+		}
+	}()
+	return nil
+}
+
+// waitPidFile waits until the pid file of the process is written in the
+// run directory, or until the process exits
+func (p *persistentDockerProcess) waitPidFile(ctx context.Context) error {
+	pidFile := path.Join(p.runDir, persistentDockerPidFile)
+	for {
+		stat, err := p.runner.cli.ContainerStatPath(ctx, p.runner.containerID, pidFile)
+		if err == nil && stat.Size > 0 {
+			return nil
+		}
+		if err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
+		select {
+		case <-p.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// release removes the run directory from the container
+func (p *persistentDockerProcess) release() error {
+	if p.hijacked != nil {
+		p.hijacked.Close()
+	}
+	if !p.staged {
+		return nil
+	}
+	ctx, cancel := cleanupContext(p.ctx)
+	defer cancel()
+	return p.runner.exec(ctx, "rm", "-rf", p.runDir)
+}
+
+func (p *persistentDockerProcess) Wait() error {
+	p.once.Do(func() {
+		<-p.done
+		<-p.watched
+		p.close()
+		ctx, cancel := cleanupContext(p.ctx)
+		code, err := p.runner.execExitCode(ctx, p.execID)
+		cancel()
+		if err == nil {
+			err = containerExitCodeError(code, false)
+		}
+		p.collectFiles(p.opts, p.runDir, func(path string) ([]byte, error) {
+			ctx, cancel := cleanupContext(p.ctx)
+			defer cancel()
+			return readDockerFile(ctx, p.runner.cli, p.runner.containerID, path)
+		})
+		p.err = multierr.Combine(p.cancelErr, p.copyErr, err, p.release())
+	})
+	return p.err
+}
+
+func (p *persistentDockerProcess) Signal(sig os.Signal) error {
+	logrus.WithField("containerID", p.runner.containerID).WithField("signal", sig).Debugf("signaling process in docker container")
+	pidFile := path.Join(p.runDir, persistentDockerPidFile)
+	return p.runner.exec(context.Background(), "/bin/sh", "-c", `kill -"$0" "$(cat "$1")"`, dockerSignal(sig), pidFile)
+}
+
+func (p *persistentDockerProcess) Pid() int {
+	info, err := p.runner.cli.ContainerExecInspect(context.Background(), p.execID)
+	if err != nil {
+		return 0
+	}
+	return info.Pid
+}
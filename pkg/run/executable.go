@@ -62,16 +62,51 @@ func (e *execRunner) WorkDir() string {
 }
 
 func (e *execRunner) Run(ctx context.Context, options ...RunnerOption) error {
+	p, err := e.Start(ctx, options...)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+func (e *execRunner) Start(ctx context.Context, options ...RunnerOption) (Process, error) {
 	e.m.Lock()
-	defer e.m.Unlock()
 	opts := buildRunOptions(options...)
-	defer os.RemoveAll(e.WorkDir())
+	release := func() {
+		os.RemoveAll(e.WorkDir())
+		e.m.Unlock()
+	}
+	if err := e.prepareFiles(opts.files); err != nil {
+		release()
+		return nil, err
+	}
+
+	// launch a process
+	res := &execProcess{processStreams: newProcessStreams(), release: release}
+	res.collect = func() { res.collectFiles(opts, e.WorkDir(), os.ReadFile) }
+	cmdLine := strings.Join(append([]string{e.executable}, opts.args...), " ")
+	logrus.WithField("cmd", cmdLine).Debugf("executing command")
+	res.cmd = exec.CommandContext(ctx, e.executable, opts.args...)
+	res.cmd.Stdout, res.cmd.Stderr = res.teeWriters(opts)
+	res.cmd.Dir = e.WorkDir()
+	for k, v := range opts.envVars {
+		res.cmd.Env = append(res.cmd.Env, fmt.Sprintf(`%s=%s`, k, v))
+	}
+	if err := res.cmd.Start(); err != nil {
+		res.close()
+		release()
+		return nil, err
+	}
+	return res, nil
+}
+
+func (e *execRunner) prepareFiles(files []FileAccessor) error {
 	if err := os.MkdirAll(e.WorkDir(), os.ModePerm); err != nil {
 		return err
 	}
 
 	// make sure all files are accessible
-	for _, f := range opts.files {
+	for _, f := range files {
 		// if file's name is a relative path, copy it in the workdir
 		if !path.IsAbs(f.Name()) {
 			newAbsPath := e.WorkDir() + "/" + f.Name()
@@ -100,21 +135,45 @@ func (e *execRunner) Run(ctx context.Context, options ...RunnerOption) error {
 			// be accessible as-is without further path mangling
 		}
 	}
+	return nil
+}
 
-	// launch a process
-	cmdLine := strings.Join(append([]string{e.executable}, opts.args...), " ")
-	logrus.WithField("cmd", cmdLine).Debugf("executing command")
-	cmd := exec.CommandContext(ctx, e.executable, opts.args...)
-	cmd.Stdout = opts.stdout
-	cmd.Stderr = opts.stderr
-	cmd.Dir = e.WorkDir()
-	for k, v := range opts.envVars {
-		cmd.Env = append(cmd.Env, fmt.Sprintf(`%s=%s`, k, v))
-	}
+type execProcess struct {
+	processStreams
+	cmd     *exec.Cmd
+	once    sync.Once
+	err     error
+	release func()
+	//
+	// collect collects the output files of the process, if not nil
+	collect func()
+	//
+	// exitCodeError converts the error of the command into an
+	// *ExitCodeError. Uses processExitCodeError if nil.
+	exitCodeError func(error) error
+}
 
-	err := cmd.Run()
-	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() != 0 {
-		err = &ExitCodeError{Code: exitErr.ExitCode()}
-	}
-	return err
+func (p *execProcess) Wait() error {
+	p.once.Do(func() {
+		p.err = p.cmd.Wait()
+		if p.exitCodeError != nil {
+			p.err = p.exitCodeError(p.err)
+		} else {
+			p.err = processExitCodeError(p.err)
+		}
+		p.close()
+		if p.collect != nil {
+			p.collect()
+		}
+		p.release()
+	})
+	return p.err
+}
+
+func (p *execProcess) Signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *execProcess) Pid() int {
+	return p.cmd.Process.Pid
 }
@@ -71,3 +71,14 @@ func (l *memFileAccessor) Name() string {
 func (l *memFileAccessor) Content() ([]byte, error) {
 	return ([]byte)(l.content), nil
 }
+
+// renamedFileAccessor is a FileAccessor with a different name
+// than the one it wraps
+type renamedFileAccessor struct {
+	FileAccessor
+	name string
+}
+
+func (r *renamedFileAccessor) Name() string {
+	return r.name
+}
@@ -0,0 +1,416 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v3"
+)
+
+// PodSpec is a simplified Kubernetes-like pod specification, describing
+// a set of containers that run together and share volumes and network.
+type PodSpec struct {
+	// Name is the name of the pod, used for logging and labeling
+	Name string
+	//
+	// Containers is the list of containers of the pod. The containers
+	// are started in order, and all share the network namespace owned by
+	// an implicit pause container (like in Kubernetes)
+	Containers []PodContainer
+	//
+	// PauseImage is the image of the implicit pause container of the pod.
+	// If empty, DefaultPodPauseImage is used. The pause container is not
+	// created if HostNetwork is true.
+	PauseImage string
+	//
+	// Volumes is the list of volumes that can be mounted in the containers
+	Volumes []PodVolume
+	//
+	// HostNetwork makes all the containers use the host's network namespace
+	HostNetwork bool
+	//
+	// HostPID makes all the containers use the host's PID namespace
+	HostPID bool
+}
+
+// DefaultPodPauseImage is the default image of the pause container
+// owning the network namespace of a pod
+const DefaultPodPauseImage = "registry.k8s.io/pause:3.9"
+
+// PodContainer is the specification of a container of a pod.
+type PodContainer struct {
+	Name       string
+	Image      string
+	Command    []string
+	Args       []string
+	Privileged bool
+	//
+	// Env is the set of environment variables of the container
+	Env map[string]string
+	//
+	// EnvFrom is a list of ConfigMap-like files from which the environment
+	// variables of the container are loaded. Each file can either be a
+	// YAML map of strings or a ConfigMap manifest, in which case the
+	// variables are loaded from its "data" field. Variables defined in Env
+	// take precedence over the ones defined in EnvFrom.
+	EnvFrom []FileAccessor
+	//
+	// VolumeMounts is the list of volumes mounted in the container
+	VolumeMounts []PodVolumeMount
+}
+
+// PodVolume is a volume of a pod. If HostPath is empty, the volume
+// is an empty directory created at each run and shared between all the
+// containers mounting it, similarly to an "emptyDir" volume in Kubernetes.
+type PodVolume struct {
+	Name     string
+	HostPath string
+}
+
+// PodVolumeMount describes the mount of a pod volume in a container.
+type PodVolumeMount struct {
+	Name      string
+	MountPath string
+	ReadOnly  bool
+}
+
+type podRunner struct {
+	m    sync.Mutex
+	spec PodSpec
+	main int
+}
+
+// NewPodRunner returns a runner that runs a pod-like set of containers
+// with Docker. The container with the given name is the one running the
+// executable under test: it receives the arguments, files, and environment
+// variables of each run, and each run lasts as long as it is running.
+// All the other containers of the pod act as sidecars, are started before
+// it, and are stopped and removed when it exits.
+func NewPodRunner(spec *PodSpec, main string) (Runner, error) {
+	res := &podRunner{spec: *spec, main: -1}
+	if err := res.validate(main); err != nil {
+		return nil, err
+	}
+	if !res.spec.HostNetwork && len(res.spec.PauseImage) == 0 {
+		res.spec.PauseImage = DefaultPodPauseImage
+	}
+
+	// attempt pulling all the images
+	err := withDockerClient(newDockerClient, func(cli *client.Client) error {
+		pulled := make(map[string]bool)
+		images := []string{}
+		if !res.spec.HostNetwork {
+			images = append(images, res.spec.PauseImage)
+		}
+		for _, c := range res.spec.Containers {
+			images = append(images, c.Image)
+		}
+		for _, image := range images {
+			if !pulled[image] {
+				if err := pullDockerImage(context.Background(), cli, image); err != nil {
+					return err
+				}
+				pulled[image] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (p *podRunner) validate(main string) error {
+	volumes := make(map[string]bool)
+	for _, v := range p.spec.Volumes {
+		if volumes[v.Name] {
+			return fmt.Errorf("duplicate volume '%s' in pod '%s'", v.Name, p.spec.Name)
+		}
+		volumes[v.Name] = true
+	}
+	containers := make(map[string]bool)
+	for i, c := range p.spec.Containers {
+		if containers[c.Name] {
+			return fmt.Errorf("duplicate container '%s' in pod '%s'", c.Name, p.spec.Name)
+		}
+		containers[c.Name] = true
+		if c.Name == main {
+			p.main = i
+		}
+		for _, m := range c.VolumeMounts {
+			if !volumes[m.Name] {
+				return fmt.Errorf("container '%s' mounts unknown volume '%s'", c.Name, m.Name)
+			}
+		}
+	}
+	if p.main < 0 {
+		return fmt.Errorf("main container '%s' not found in pod '%s'", main, p.spec.Name)
+	}
+	return nil
+}
+
+func (p *podRunner) WorkDir() string {
+	// note: the working directory is the root dir of the main container
+	return "/"
+}
+
+func (p *podRunner) Run(ctx context.Context, options ...RunnerOption) error {
+	proc, err := p.Start(ctx, options...)
+	if err != nil {
+		return err
+	}
+	return proc.Wait()
+}
+
+func (p *podRunner) Start(ctx context.Context, options ...RunnerOption) (Process, error) {
+	p.m.Lock()
+	opts := buildRunOptions(options...)
+	cli, err := newDockerClient()
+	if err != nil {
+		p.m.Unlock()
+		return nil, err
+	}
+	pod := &podInstance{runner: p, ctx: ctx, cli: cli}
+	res := newDockerProcess(ctx, cli, p.WorkDir())
+	res.release = pod.release
+
+	// create all the volumes and containers, copy all loaded files
+	// into the main one, and start the pause container and all the
+	// sidecars before it
+	err = pod.create(ctx, opts)
+	if err == nil {
+		res.containerID = pod.containerIDs[p.main]
+		err = copyDockerFiles(ctx, cli, res.containerID, p.WorkDir(), opts.files)
+	}
+	if err == nil {
+		err = pod.startSidecars(ctx)
+	}
+	if err == nil {
+		err = res.start(ctx, opts)
+	}
+	if err != nil {
+		res.close()
+		return nil, multierr.Append(err, res.shutdown())
+	}
+	return res, nil
+}
+
+// podInstance holds the resources of a single run of a pod
+type podInstance struct {
+	runner       *podRunner
+	ctx          context.Context
+	cli          *client.Client
+	volumes      map[string]string
+	pauseID      string
+	containerIDs []string
+	started      []string
+}
+
+func (i *podInstance) create(ctx context.Context, opts *runOpts) error {
+	spec := &i.runner.spec
+	i.volumes = make(map[string]string)
+	for _, v := range spec.Volumes {
+		if len(v.HostPath) > 0 {
+			i.volumes[v.Name] = v.HostPath
+			continue
+		}
+		logrus.WithField("pod", spec.Name).WithField("volume", v.Name).Debugf("creating docker volume")
+		vol, err := i.cli.VolumeCreate(ctx, volume.CreateOptions{
+			Labels: map[string]string{"falcosecurity.testing.pod": spec.Name},
+		})
+		if err != nil {
+			return err
+		}
+		i.volumes[v.Name] = vol.Name
+	}
+
+	if !spec.HostNetwork {
+		logrus.WithField("pod", spec.Name).WithField("image", spec.PauseImage).Debugf("creating pause container")
+		// note: the ports are published by the pause container, which
+		// owns the network namespace shared by the whole pod
+		config := &container.Config{
+			Image:  spec.PauseImage,
+			Labels: map[string]string{"falcosecurity.testing.pod": spec.Name},
+		}
+		hostConfig := &container.HostConfig{}
+		publishDockerPorts(config, hostConfig, opts.ports)
+		resp, err := i.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+		if err != nil {
+			return err
+		}
+		i.pauseID = resp.ID
+	}
+
+	for n, c := range spec.Containers {
+		env, err := podContainerEnv(&c)
+		if err != nil {
+			return err
+		}
+		config := &container.Config{
+			Image:  c.Image,
+			Labels: map[string]string{"falcosecurity.testing.pod": spec.Name},
+		}
+		if len(c.Command) > 0 {
+			config.Entrypoint = strslice.StrSlice(c.Command)
+		}
+		config.Cmd = strslice.StrSlice(c.Args)
+		if n == i.runner.main {
+			config.Cmd = append(append(strslice.StrSlice{}, c.Args...), opts.args...)
+			for k, v := range opts.envVars {
+				env[k] = v
+			}
+		}
+		for _, k := range sortedKeys(env) {
+			config.Env = append(config.Env, fmt.Sprintf(`%s=%s`, k, env[k]))
+		}
+
+		hostConfig := &container.HostConfig{Privileged: c.Privileged}
+		for _, m := range c.VolumeMounts {
+			bind := i.volumes[m.Name] + ":" + m.MountPath
+			if m.ReadOnly {
+				bind += ":ro"
+			}
+			hostConfig.Binds = append(hostConfig.Binds, bind)
+		}
+		if spec.HostPID {
+			hostConfig.PidMode = "host"
+		}
+		if spec.HostNetwork {
+			hostConfig.NetworkMode = "host"
+		} else {
+			hostConfig.NetworkMode = container.NetworkMode("container:" + i.pauseID)
+		}
+
+		logrus.WithField("pod", spec.Name).WithField("container", c.Name).WithField("image", c.Image).Debugf("creating new docker container")
+		resp, err := i.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+		if err != nil {
+			return err
+		}
+		i.containerIDs = append(i.containerIDs, resp.ID)
+	}
+	return nil
+}
+
+// startSidecars starts the pause container and all the containers
+// of the pod except the main one
+func (i *podInstance) startSidecars(ctx context.Context) error {
+	if len(i.pauseID) > 0 {
+		if err := startDockerContainer(ctx, i.cli, i.pauseID); err != nil {
+			return err
+		}
+		i.started = append(i.started, i.pauseID)
+	}
+	for n, id := range i.containerIDs {
+		if n == i.runner.main {
+			continue
+		}
+		if err := startDockerContainer(ctx, i.cli, id); err != nil {
+			return err
+		}
+		i.started = append(i.started, id)
+	}
+	return nil
+}
+
+// release stops and removes all the sidecars, containers, and volumes
+// of the pod and makes the runner available for other executions
+func (i *podInstance) release() (err error) {
+	defer i.runner.m.Unlock()
+	defer func() { err = multierr.Append(err, i.cli.Close()) }()
+
+	// stop sidecars in reverse order, so that the pause container owning
+	// the network namespace is the last one to be stopped
+	for n := len(i.started) - 1; n >= 0; n-- {
+		err = multierr.Append(err, stopDockerContainer(i.ctx, i.cli, i.started[n]))
+	}
+	for n := len(i.containerIDs) - 1; n >= 0; n-- {
+		err = multierr.Append(err, removeDockerContainer(i.ctx, i.cli, i.containerIDs[n]))
+	}
+	if len(i.pauseID) > 0 {
+		err = multierr.Append(err, removeDockerContainer(i.ctx, i.cli, i.pauseID))
+	}
+	for _, v := range i.runner.spec.Volumes {
+		if len(v.HostPath) == 0 && len(i.volumes[v.Name]) > 0 {
+			logrus.WithField("pod", i.runner.spec.Name).WithField("volume", v.Name).Debugf("removing docker volume")
+			ctx, cancel := cleanupContext(i.ctx)
+			err = multierr.Append(err, i.cli.VolumeRemove(ctx, i.volumes[v.Name], true))
+			cancel()
+		}
+	}
+	return err
+}
+
+// podContainerEnv returns the environment variables of a pod container
+func podContainerEnv(c *PodContainer) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, f := range c.EnvFrom {
+		content, err := f.Content()
+		if err != nil {
+			return nil, err
+		}
+		env, err := parseConfigMapData(content)
+		if err != nil {
+			return nil, fmt.Errorf("can't load env of container '%s' from '%s': %s", c.Name, f.Name(), err.Error())
+		}
+		for k, v := range env {
+			res[k] = v
+		}
+	}
+	for k, v := range c.Env {
+		res[k] = v
+	}
+	return res, nil
+}
+
+// parseConfigMapData parses either a ConfigMap manifest or a plain YAML
+// map of strings, and returns its key-value data
+func parseConfigMapData(content []byte) (map[string]string, error) {
+	var manifest struct {
+		Kind string            `yaml:"kind"`
+		Data map[string]string `yaml:"data"`
+	}
+	if err := yaml.Unmarshal(content, &manifest); err == nil && manifest.Kind == "ConfigMap" {
+		return manifest.Data, nil
+	}
+	var res map[string]string
+	if err := yaml.Unmarshal(content, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodSpecValidation(t *testing.T) {
+	spec := &PodSpec{
+		Name: "test",
+		Containers: []PodContainer{
+			{Name: "sidecar", Image: testDockerImage},
+			{Name: "main", Image: testDockerImage, VolumeMounts: []PodVolumeMount{{Name: "shared", MountPath: "/shared"}}},
+		},
+		Volumes: []PodVolume{{Name: "shared"}},
+	}
+	require.Nil(t, (&podRunner{spec: *spec, main: -1}).validate("main"))
+	require.NotNil(t, (&podRunner{spec: *spec, main: -1}).validate("unknown"))
+	require.Nil(t, (&podRunner{spec: *spec, main: -1}).validate("sidecar"))
+
+	spec.Volumes = nil
+	require.NotNil(t, (&podRunner{spec: *spec, main: -1}).validate("main"))
+}
+
+func TestPodContainerEnv(t *testing.T) {
+	c := &PodContainer{
+		Name: "test",
+		EnvFrom: []FileAccessor{
+			NewStringFileAccessor("plain.yaml", "A: a\nB: b\n"),
+			NewStringFileAccessor("configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\ndata:\n  B: configmap\n  C: c\n"),
+		},
+		Env: map[string]string{"C": "env"},
+	}
+	env, err := podContainerEnv(c)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{"A": "a", "B": "configmap", "C": "env"}, env)
+
+	c.EnvFrom = []FileAccessor{NewStringFileAccessor("invalid.yaml", "- A\n")}
+	_, err = podContainerEnv(c)
+	require.NotNil(t, err)
+}
+
+func TestPodRunner(t *testing.T) {
+	sidecar := PodContainer{
+		Name:         "sidecar",
+		Image:        testDockerImage,
+		Command:      []string{"/bin/sh", "-c"},
+		Args:         []string{"echo hello > /shared/hello; exec sleep 60"},
+		VolumeMounts: []PodVolumeMount{{Name: "shared", MountPath: "/shared"}},
+	}
+	main := PodContainer{
+		Name:         "main",
+		Image:        testDockerImage,
+		Command:      []string{"/bin/sh", "-c"},
+		EnvFrom:      []FileAccessor{NewStringFileAccessor("env.yaml", "GREETING: world\n")},
+		VolumeMounts: []PodVolumeMount{{Name: "shared", MountPath: "/shared", ReadOnly: true}},
+	}
+	for name, containers := range map[string][]PodContainer{
+		"main-last":  {sidecar, main},
+		"main-first": {main, sidecar},
+	} {
+		containers := containers
+		t.Run(name, func(t *testing.T) {
+			runner, err := NewPodRunner(&PodSpec{
+				Name:       "test",
+				Containers: containers,
+				Volumes:    []PodVolume{{Name: "shared"}},
+			}, "main")
+			require.Nil(t, err)
+			var out bytes.Buffer
+			err = runner.Run(
+				context.Background(),
+				WithStdout(&out),
+				WithArgs(`while [ ! -f /shared/hello ]; do sleep 0.1; done; echo "$(cat /shared/hello) $GREETING"`),
+			)
+			require.Nil(t, err)
+			require.Equal(t, "hello world\n", out.String())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PodmanHostEnv is the environment variable used to specify the
+	// address of the Podman API socket
+	PodmanHostEnv = "CONTAINER_HOST"
+	//
+	// PodmanRootSocket is the default Podman API socket for rootful Podman
+	PodmanRootSocket = "/run/podman/podman.sock"
+)
+
+// NewPodmanRunner returns a runner that runs a container image with Podman,
+// through the Docker-compatible API served by the Podman API socket.
+// The socket address is read from the CONTAINER_HOST environment variable
+// if set, otherwise the rootless socket of the current user is used
+// if available, with the rootful one as a fallback.
+func NewPodmanRunner(image, entrypoint string, options *DockerRunnerOptions) (Runner, error) {
+	return newDockerRunner(image, entrypoint, options, newPodmanClient)
+}
+
+func newPodmanClient() (*client.Client, error) {
+	host := podmanHost()
+	logrus.WithField("host", host).Debugf("creating new podman client")
+	return client.NewClientWithOpts(client.WithHost(host), client.WithAPIVersionNegotiation())
+}
+
+func podmanHost() string {
+	if host := os.Getenv(PodmanHostEnv); len(host) > 0 {
+		return host
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		socket := filepath.Join(dir, "podman", "podman.sock")
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket
+		}
+	}
+	return "unix://" + PodmanRootSocket
+}
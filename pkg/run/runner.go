@@ -22,14 +22,23 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 type runOpts struct {
-	stderr  io.Writer
-	stdout  io.Writer
-	args    []string
-	files   []FileAccessor
-	envVars map[string]string
+	stderr      io.Writer
+	stdout      io.Writer
+	args        []string
+	files       []FileAccessor
+	outputFiles []string
+	envVars     map[string]string
+	ports       []int
 }
 
 // RunnerOption is an option for running Falco
@@ -41,11 +50,44 @@ type Runner interface {
 	// execution or when the context deadline is exceeded.
 	// Returns a non-nil error in case of failure.
 	Run(ctx context.Context, options ...RunnerOption) error
+	// Start runs Falco with the given options and returns as soon as the
+	// execution is started, with a handle for interacting with it.
+	// The runner can't be used for other executions until the returned
+	// process handle has been waited.
+	// Returns a non-nil error in case of failure.
+	Start(ctx context.Context, options ...RunnerOption) (Process, error)
 	// WorkDir return the absolute path to the working directory assigned
 	// to the runner.
 	WorkDir() string
 }
 
+// Process is a handle to an execution started asynchronously by a Runner
+type Process interface {
+	// Wait blocks until the execution finishes and releases all the
+	// resources associated to it. Returns a non-nil error in case of failure,
+	// with the same semantics of Runner.Run. Calling Wait more than once
+	// returns the same result.
+	Wait() error
+	// Signal sends a signal to the running process.
+	Signal(sig os.Signal) error
+	// Pid returns the identifier of the running process, as seen from the
+	// host. Returns 0 if the identifier can't be retrieved.
+	Pid() int
+	// Stdout returns a new reader for the stdout of the running process.
+	// Every reader receives the whole output starting from its beginning,
+	// blocks while waiting for new lines, and returns io.EOF once
+	// the process exits.
+	Stdout() io.Reader
+	// Stderr returns a new reader for the stderr of the running process,
+	// with the same semantics of Stdout.
+	Stderr() io.Reader
+	// OutputFiles returns the files collected after the execution as
+	// requested with WithOutputFiles, named with the requested paths.
+	// Files that were not produced by the execution are not included.
+	// The result is available only after Wait returns.
+	OutputFiles() []FileAccessor
+}
+
 // WithFiles is an option for running Falco with some files
 // to be used during execution and/or referenced in the CLI args
 // (e.g. rules files, config files, capture files, etc...).
@@ -55,6 +97,27 @@ func WithFiles(files ...FileAccessor) RunnerOption {
 	return func(ro *runOpts) { ro.files = append(ro.files, files...) }
 }
 
+// WithOutputFiles is an option for collecting the files with the given
+// paths produced by Falco during execution (e.g. file outputs, capture
+// files, etc...). Relative paths are resolved from the working directory
+// of the runner. The files are collected once the execution finishes and
+// before the runner cleans up its resources, and are available through
+// Process.OutputFiles.
+func WithOutputFiles(paths ...string) RunnerOption {
+	return func(ro *runOpts) { ro.outputFiles = append(ro.outputFiles, paths...) }
+}
+
+// WithPorts is an option for making the given TCP ports on which Falco
+// listens (e.g. webserver, gRPC server, etc...) reachable from the host
+// through the loopback interface with the same port numbers. Runners
+// sharing the host's network (e.g. the executable runner) don't need this
+// and ignore it, whereas runners based on Docker or containerd publish
+// the ports of the containers they create. Runners that can't publish
+// the ports fail to start instead of ignoring them.
+func WithPorts(ports ...int) RunnerOption {
+	return func(ro *runOpts) { ro.ports = append(ro.ports, ports...) }
+}
+
 // WithArgs is an option for running Falco with a given set of CLI arguments
 func WithArgs(args ...string) RunnerOption {
 	return func(ro *runOpts) { ro.args = append(ro.args, args...) }
@@ -81,13 +144,125 @@ func WithEnvVars(vars map[string]string) RunnerOption {
 	}
 }
 
-// ExitCodeError is an error representing the exit code of Falco
+// ExitCodeError is an error representing the exit code of Falco.
+// Processes terminated by a signal have exit code 128 plus the
+// signal number, following the convention of shells and container runtimes.
 type ExitCodeError struct {
 	Code int
+	//
+	// Signal is the signal that terminated the process,
+	// or 0 if the process exited on its own
+	Signal syscall.Signal
+	//
+	// OOMKilled is true if the process has been killed for
+	// exceeding its memory limit
+	OOMKilled bool
 }
 
 func (c *ExitCodeError) Error() string {
-	return fmt.Sprintf("error code %d", c.Code)
+	res := fmt.Sprintf("error code %d", c.Code)
+	if c.Signal != 0 {
+		res += fmt.Sprintf(", terminated by signal %d (%s)", int(c.Signal), c.Signal.String())
+	}
+	if c.OOMKilled {
+		res += ", killed for running out of memory"
+	}
+	return res
+}
+
+// processExitCodeError converts the exit error of a local process into an
+// *ExitCodeError, or returns the error as-is if it's not related to exit codes
+func processExitCodeError(err error) error {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return &ExitCodeError{Code: 128 + int(status.Signal()), Signal: status.Signal()}
+	}
+	if exitErr.ExitCode() != 0 {
+		return &ExitCodeError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// containerExitCodeError returns an *ExitCodeError from the exit status of a
+// container, or nil if it succeeded. Container runtimes report processes
+// terminated by a signal with exit code 128 plus the signal number.
+func containerExitCodeError(code int, oomKilled bool) error {
+	if code == 0 && !oomKilled {
+		return nil
+	}
+	res := &ExitCodeError{Code: code, OOMKilled: oomKilled}
+	if code > 128 && code < 128+65 {
+		res.Signal = syscall.Signal(code - 128)
+	}
+	return res
+}
+
+// processStreams is the implementation of the output streaming and the
+// output files collection of Process shared by all runners
+type processStreams struct {
+	stdout *stream
+	stderr *stream
+	files  []FileAccessor
+}
+
+func newProcessStreams() processStreams {
+	return processStreams{stdout: newStream(), stderr: newStream()}
+}
+
+func (p *processStreams) Stdout() io.Reader {
+	return p.stdout.NewReader()
+}
+
+func (p *processStreams) Stderr() io.Reader {
+	return p.stderr.NewReader()
+}
+
+// teeWriters returns writers forwarding data to both the writers of the
+// given options and to the process streams
+func (p *processStreams) teeWriters(opts *runOpts) (stdout, stderr io.Writer) {
+	return io.MultiWriter(opts.stdout, p.stdout), io.MultiWriter(opts.stderr, p.stderr)
+}
+
+func (p *processStreams) OutputFiles() []FileAccessor {
+	return p.files
+}
+
+// collectFiles collects the output files requested in the given options
+// by reading them with a runner-specific function. Relative paths are
+// resolved from the given working directory.
+func (p *processStreams) collectFiles(opts *runOpts, workDir string, read func(path string) ([]byte, error)) {
+	for _, name := range opts.outputFiles {
+		filePath := name
+		if !path.IsAbs(filePath) {
+			filePath = path.Join(workDir, filePath)
+		}
+		content, err := read(filePath)
+		if err != nil {
+			logrus.WithField("path", filePath).WithError(err).Debugf("can't collect output file")
+			continue
+		}
+		p.files = append(p.files, NewBytesFileAccessor(name, content))
+	}
+}
+
+func (p *processStreams) close() {
+	p.stdout.Close()
+	p.stderr.Close()
+}
+
+// cleanupTimeout is the max duration of each operation waiting for
+// a process or releasing its resources
+const cleanupTimeout = time.Minute
+
+// cleanupContext returns a context for waiting for a process and releasing
+// its resources, which must happen even if the given context of the process
+// is already done. The returned context keeps the values of the given one,
+// and expires after cleanupTimeout.
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
 }
 
 func buildRunOptions(opts ...RunnerOption) *runOpts {
@@ -19,31 +19,73 @@ limitations under the License.
 package run
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 )
 
 const (
-	testDockerImage = "ubuntu:latest"
+	testDockerImage = "docker.io/library/ubuntu:latest"
 )
 
-func TestFileAccess(t *testing.T) {
-	runners := map[string]func() (Runner, error){
-		"executable": func() (Runner, error) { return NewExecutableRunner("/bin/cat") },
-		"docker":     func() (Runner, error) { return NewDockerRunner(testDockerImage, "/bin/cat", nil) },
+// testRunners returns the constructors of runners of all the supported
+// backends for the given entrypoint. The optional backends make the test
+// skip if they are not available in the current environment.
+func testRunners(entrypoint string) map[string]func(t *testing.T) Runner {
+	return map[string]func(t *testing.T) Runner{
+		"executable": func(t *testing.T) Runner {
+			return requireTestRunner(t)(NewExecutableRunner(entrypoint))
+		},
+		"docker": func(t *testing.T) Runner {
+			return requireTestRunner(t)(NewDockerRunner(testDockerImage, entrypoint, nil))
+		},
+		"podman": func(t *testing.T) Runner {
+			return skipTestRunner(t, "podman")(NewPodmanRunner(testDockerImage, entrypoint, nil))
+		},
+		"containerd": func(t *testing.T) Runner {
+			return skipTestRunner(t, "containerd")(NewContainerdRunner(testDockerImage, entrypoint, nil))
+		},
 	}
+}
+
+// requireTestRunner makes the test fail if a runner can't be created
+func requireTestRunner(t *testing.T) func(Runner, error) Runner {
+	return func(r Runner, err error) Runner {
+		require.Nil(t, err)
+		return r
+	}
+}
+
+// skipTestRunner makes the test skip if a runner can't be created
+func skipTestRunner(t *testing.T, backend string) func(Runner, error) Runner {
+	return func(r Runner, err error) Runner {
+		if err != nil {
+			t.Skipf("can't run with %s: %s", backend, err.Error())
+		}
+		return r
+	}
+}
+
+func TestFileAccess(t *testing.T) {
+	runners := testRunners("/bin/cat")
 	for rName, rCons := range runners {
 		t.Run(rName, func(t *testing.T) {
 			str := "hello world"
 			file := NewStringFileAccessor("testdir/some-file", str)
-			runner, err := rCons()
-			require.Nil(t, err)
+			runner := rCons(t)
 			var out bytes.Buffer
-			err = runner.Run(
+			err := runner.Run(
 				context.Background(),
 				WithStdout(&out),
 				WithFiles(file),
@@ -57,17 +99,13 @@ func TestFileAccess(t *testing.T) {
 
 func TestInputOutput(t *testing.T) {
 	logrus.SetLevel(logrus.DebugLevel)
-	runners := map[string]func() (Runner, error){
-		"executable": func() (Runner, error) { return NewExecutableRunner("/bin/echo") },
-		"docker":     func() (Runner, error) { return NewDockerRunner(testDockerImage, "/bin/echo", nil) },
-	}
+	runners := testRunners("/bin/echo")
 	for rName, rCons := range runners {
 		t.Run(rName, func(t *testing.T) {
-			runner, err := rCons()
-			require.Nil(t, err)
+			runner := rCons(t)
 			str := "hello world"
 			var out bytes.Buffer
-			err = runner.Run(
+			err := runner.Run(
 				context.Background(),
 				WithStdout(&out),
 				WithArgs(str),
@@ -77,3 +115,222 @@ func TestInputOutput(t *testing.T) {
 		})
 	}
 }
+
+func TestExitCode(t *testing.T) {
+	runners := testRunners("/bin/sh")
+	for rName, rCons := range runners {
+		t.Run(rName, func(t *testing.T) {
+			runner := rCons(t)
+			require.Nil(t, runner.Run(context.Background(), WithArgs("-c", "exit 0")))
+
+			var exitErr *ExitCodeError
+			err := runner.Run(context.Background(), WithArgs("-c", "exit 3"))
+			require.True(t, errors.As(err, &exitErr), "%v", err)
+			require.Equal(t, &ExitCodeError{Code: 3}, exitErr)
+		})
+	}
+}
+
+func TestStart(t *testing.T) {
+	runners := testRunners("/bin/sh")
+	for rName, rCons := range runners {
+		t.Run(rName, func(t *testing.T) {
+			runner := rCons(t)
+			var out bytes.Buffer
+			p, err := runner.Start(
+				context.Background(),
+				WithStdout(&out),
+				WithArgs("-c", "echo ready; exec sleep 60"),
+			)
+			require.Nil(t, err)
+			require.NotZero(t, p.Pid())
+
+			// the first line must be readable while the process is running
+			scanner := bufio.NewScanner(p.Stdout())
+			require.True(t, scanner.Scan())
+			require.Equal(t, "ready", scanner.Text())
+			require.Nil(t, p.Signal(syscall.SIGKILL))
+
+			var exitErr *ExitCodeError
+			err = p.Wait()
+			require.True(t, errors.As(err, &exitErr), "%v", err)
+			require.Equal(t, syscall.SIGKILL, exitErr.Signal)
+			require.Equal(t, 137, exitErr.Code)
+			require.False(t, scanner.Scan())
+			require.Equal(t, "ready\n", out.String())
+			require.Equal(t, err, p.Wait())
+		})
+	}
+}
+
+func TestPersistentDockerRunner(t *testing.T) {
+	runner, err := NewPersistentDockerRunner(testDockerImage, "/bin/sh", nil)
+	require.Nil(t, err)
+	defer func() { require.Nil(t, runner.Close()) }()
+
+	// concurrent runs must have their own files
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			str := fmt.Sprintf("hello %d", i)
+			var out bytes.Buffer
+			err := runner.Run(
+				context.Background(),
+				WithStdout(&out),
+				WithFiles(NewStringFileAccessor("testdir/some-file", str)),
+				WithArgs("-c", "cat testdir/some-file"),
+			)
+			require.Nil(t, err)
+			require.Equal(t, str, out.String())
+		}(i)
+	}
+	wg.Wait()
+
+	// absolute paths in the working dir must refer to the run's own files
+	file := NewStringFileAccessor("testdir/some-file", "hello world")
+	var out bytes.Buffer
+	err = runner.Run(
+		context.Background(),
+		WithStdout(&out),
+		WithFiles(file),
+		WithEnvVars(map[string]string{"FILE": runner.WorkDir() + "/" + file.Name()}),
+		WithArgs("-c", `cat "$0" && cp "$FILE" "$1"`, runner.WorkDir()+"/"+file.Name(), runner.WorkDir()+"/copy"),
+	)
+	require.Nil(t, err)
+	require.Equal(t, "hello world", out.String())
+
+	// exit codes and signals must be reported for each run, and
+	// processes must be signalable as soon as they start
+	var exitErr *ExitCodeError
+	err = runner.Run(context.Background(), WithArgs("-c", "exit 3"))
+	require.True(t, errors.As(err, &exitErr), "%v", err)
+	require.Equal(t, 3, exitErr.Code)
+	p, err := runner.Start(context.Background(), WithArgs("-c", "exec sleep 60"))
+	require.Nil(t, err)
+	require.Nil(t, p.Signal(syscall.SIGKILL))
+	require.True(t, errors.As(p.Wait(), &exitErr))
+}
+
+func TestResolveRunDir(t *testing.T) {
+	opts := resolveRunDir(&runOpts{
+		args:        []string{"-c", "/work/file", "/work", "/workdir/file", "relative"},
+		envVars:     map[string]string{"A": "x=/work/file"},
+		outputFiles: []string{"/work/out", "out"},
+		files: []FileAccessor{
+			NewStringFileAccessor("/work/abs", "a"),
+			NewStringFileAccessor("/etc/abs", "b"),
+			NewStringFileAccessor("rel", "c"),
+		},
+	}, "/work", "/work/1")
+	require.Equal(t, []string{"-c", "/work/1/file", "/work/1", "/workdir/file", "relative"}, opts.args)
+	require.Equal(t, map[string]string{"A": "x=/work/1/file"}, opts.envVars)
+	require.Equal(t, []string{"/work/1/out", "out"}, opts.outputFiles)
+	var names []string
+	for _, f := range opts.files {
+		names = append(names, f.Name())
+	}
+	require.Equal(t, []string{"/work/1/abs", "/etc/abs", "rel"}, names)
+	content, err := opts.files[0].Content()
+	require.Nil(t, err)
+	require.Equal(t, "a", string(content))
+}
+
+func TestDockerRunnerOptions(t *testing.T) {
+	opts := &DockerRunnerOptions{
+		CapAdd:         []string{"SYS_ADMIN", "SYS_RESOURCE"},
+		CapDrop:        []string{"ALL"},
+		NetworkMode:    "none",
+		Memory:         256 * 1024 * 1024,
+		CPUs:           1.5,
+		ReadonlyRootfs: true,
+		Tmpfs:          map[string]string{"/tmp": "rw,size=64m"},
+		ExtraHosts:     []string{"falcosidekick:127.0.0.1"},
+	}
+	config := opts.hostConfig()
+	require.Equal(t, []string{"SYS_ADMIN", "SYS_RESOURCE"}, []string(config.CapAdd))
+	require.Equal(t, []string{"ALL"}, []string(config.CapDrop))
+	require.True(t, config.NetworkMode.IsNone())
+	require.Equal(t, int64(256*1024*1024), config.Memory)
+	require.Equal(t, int64(1500000000), config.NanoCPUs)
+	require.True(t, config.ReadonlyRootfs)
+	require.Equal(t, opts.Tmpfs, config.Tmpfs)
+	require.Equal(t, opts.ExtraHosts, config.ExtraHosts)
+}
+
+func TestDockerReadonlyRootfs(t *testing.T) {
+	runner, err := NewDockerRunner(testDockerImage, "/bin/sh", &DockerRunnerOptions{ReadonlyRootfs: true})
+	require.Nil(t, err)
+	require.NotEqual(t, "/", runner.WorkDir())
+	file := NewStringFileAccessor("testdir/some-file", "hello world")
+	absFile := NewStringFileAccessor(runner.WorkDir()+"/abs-file", "!")
+	var out bytes.Buffer
+	err = runner.Run(
+		context.Background(),
+		WithStdout(&out),
+		WithFiles(file, absFile),
+		WithArgs("-c", `cat "$0" "$1" && ! touch /root-file`, runner.WorkDir()+"/"+file.Name(), absFile.Name()),
+	)
+	require.Nil(t, err)
+	require.Equal(t, "hello world!", out.String())
+
+	// files outside of the writable mounts can't be copied
+	err = runner.Run(
+		context.Background(),
+		WithFiles(NewStringFileAccessor("/etc/some-file", "hello")),
+		WithArgs("-c", "exit 0"),
+	)
+	require.NotNil(t, err)
+}
+
+func TestPublishDockerPorts(t *testing.T) {
+	t.Run("bridge", func(t *testing.T) {
+		config, hostConfig := &container.Config{}, (&DockerRunnerOptions{}).hostConfig()
+		publishDockerPorts(config, hostConfig, []int{8765, 5060})
+		require.Len(t, config.ExposedPorts, 2)
+		require.Contains(t, config.ExposedPorts, nat.Port("8765/tcp"))
+		require.Equal(t, []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: "5060"}}, hostConfig.PortBindings[nat.Port("5060/tcp")])
+	})
+	t.Run("host", func(t *testing.T) {
+		config, hostConfig := &container.Config{}, (&DockerRunnerOptions{NetworkMode: "host"}).hostConfig()
+		publishDockerPorts(config, hostConfig, []int{8765})
+		require.Empty(t, config.ExposedPorts)
+		require.Empty(t, hostConfig.PortBindings)
+	})
+}
+
+func TestOutputFiles(t *testing.T) {
+	runners := testRunners("/bin/sh")
+	for rName, rCons := range runners {
+		t.Run(rName, func(t *testing.T) {
+			runner := rCons(t)
+			p, err := runner.Start(
+				context.Background(),
+				WithArgs("-c", "mkdir -p testdir && echo hello > testdir/some-file"),
+				WithOutputFiles("testdir/some-file", "testdir/missing-file"),
+			)
+			require.Nil(t, err)
+			require.Nil(t, p.Wait())
+			require.Len(t, p.OutputFiles(), 1)
+			require.Equal(t, "testdir/some-file", p.OutputFiles()[0].Name())
+			content, err := p.OutputFiles()[0].Content()
+			require.Nil(t, err)
+			require.Equal(t, "hello\n", string(content))
+		})
+	}
+}
+
+func TestCleanupContext(t *testing.T) {
+	type key struct{}
+	parent, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "value"))
+	cancel()
+
+	ctx, cancel := cleanupContext(parent)
+	defer cancel()
+	require.Nil(t, ctx.Err())
+	require.Equal(t, "value", ctx.Value(key{}))
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now().Add(cleanupTimeout), deadline, time.Second)
+}
@@ -0,0 +1,356 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+)
+
+const (
+	sshRunnerPidFileSuffix = ".pid"
+	sshErrorExitCode       = 255
+)
+
+type sshRunner struct {
+	m          sync.Mutex
+	host       string
+	port       string
+	user       string
+	keyPath    string
+	executable string
+	workDir    string
+}
+
+// NewSSHRunner returns a runner that runs an executable binary on a remote
+// host through SSH, authenticating as the given user with the private key
+// at the given path. The host can optionally include a port
+// (e.g. "10.0.0.1:2222"). Files are uploaded with SFTP in a temporary
+// working directory on the remote host. The runner uses the OpenSSH
+// "ssh" and "sftp" client executables, which must be available locally.
+// Host keys are not verified, because the runner is meant to be used
+// with disposable hosts. The ports requested with WithPorts are forwarded
+// from the local host while the executable runs. Since ssh does not report
+// the signals terminating remote commands, processes terminated by a signal
+// that was not sent through Process.Signal are reported with exit code 255.
+func NewSSHRunner(host, user, keyPath, executable string) (Runner, error) {
+	res := &sshRunner{host: host, user: user, keyPath: keyPath, executable: executable}
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		res.host, res.port = h, p
+	}
+
+	// make sure that the host is reachable and that the executable
+	// is available, then create a working directory
+	_, err := res.output(context.Background(), "test -x "+shellQuote(executable))
+	if err != nil {
+		return nil, fmt.Errorf("can't access executable '%s' on host '%s': %s", executable, host, err.Error())
+	}
+	res.workDir, err = res.output(context.Background(), "mktemp -d -t "+execRunnerWorkDirPrefix+"XXXXXX")
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *sshRunner) WorkDir() string {
+	// note: this is constant after construction and does not need
+	// mutex protection
+	return s.workDir
+}
+
+func (s *sshRunner) Run(ctx context.Context, options ...RunnerOption) error {
+	p, err := s.Start(ctx, options...)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
+
+func (s *sshRunner) Start(ctx context.Context, options ...RunnerOption) (Process, error) {
+	s.m.Lock()
+	opts := buildRunOptions(options...)
+	res := &sshProcess{ctx: ctx, runner: s}
+	res.execProcess = &execProcess{
+		processStreams: newProcessStreams(),
+		release: func() {
+			if err := res.release(); err != nil {
+				logrus.WithField("host", s.host).Errorf("can't release ssh runner: %s", err.Error())
+			}
+		},
+		// note: ssh exits with the same exit code of the remote command,
+		// or with 255 if an error occurred or if the remote command got
+		// terminated by a signal. In the latter case, the signal is known
+		// only if it was sent through the process itself.
+		exitCodeError: func(err error) error {
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() >= 0 {
+				code := exitErr.ExitCode()
+				if sig := res.lastSignal(); code == sshErrorExitCode && sig != 0 {
+					code = 128 + int(sig)
+				}
+				return containerExitCodeError(code, false)
+			}
+			return processExitCodeError(err)
+		},
+	}
+	res.collect = func() {
+		res.collectFiles(opts, s.WorkDir(), func(path string) ([]byte, error) {
+			return s.download(ctx, path)
+		})
+	}
+	if err := s.upload(ctx, opts.files); err != nil {
+		res.close()
+		return nil, multierr.Append(err, res.release())
+	}
+
+	// launch the executable from a shell that stores its pid before
+	// exec'ing, so that it can be signaled later on
+	var env []string
+	for _, k := range sortedKeys(opts.envVars) {
+		env = append(env, shellQuote(fmt.Sprintf(`%s=%s`, k, opts.envVars[k])))
+	}
+	cmdLine := []string{
+		"cd", shellQuote(s.WorkDir()), "&&",
+		"exec", "env", strings.Join(env, " "),
+		"sh", "-c", shellQuote(`echo $$ > "$0" && exec "$@"`),
+		shellQuote(s.pidFile()), shellQuote(s.executable),
+	}
+	for _, arg := range opts.args {
+		cmdLine = append(cmdLine, shellQuote(arg))
+	}
+	logrus.WithField("host", s.host).WithField("cmd", strings.Join(append([]string{s.executable}, opts.args...), " ")).Debugf("executing command through ssh")
+	res.cmd = s.command(ctx, strings.Join(cmdLine, " "), sshPortForwards(opts.ports)...)
+	res.cmd.Stdout, res.cmd.Stderr = res.teeWriters(opts)
+	res.cmd.Cancel = func() error {
+		// note: the remote process is not terminated when the
+		// ssh connection gets closed, so we need to kill it first
+		return multierr.Append(res.Signal(os.Kill), res.cmd.Process.Kill())
+	}
+	if err := res.cmd.Start(); err != nil {
+		res.close()
+		return nil, multierr.Append(err, res.release())
+	}
+	return res, nil
+}
+
+// pidFile returns the remote path of the file in which the pid of the
+// running executable is stored
+func (s *sshRunner) pidFile() string {
+	return s.WorkDir() + sshRunnerPidFileSuffix
+}
+
+// destination returns the user and host that ssh and sftp connect to
+func (s *sshRunner) destination() string {
+	if len(s.user) > 0 {
+		return s.user + "@" + s.host
+	}
+	return s.host
+}
+
+// options returns the options shared by the ssh and sftp clients
+func (s *sshRunner) options() []string {
+	res := []string{
+		"-o", "BatchMode=yes",
+		"-o", "StrictHostKeyChecking=no",
+		"-o", "UserKnownHostsFile=/dev/null",
+		"-o", "LogLevel=ERROR",
+	}
+	if len(s.keyPath) > 0 {
+		res = append(res, "-i", s.keyPath)
+	}
+	return res
+}
+
+// command returns a ssh command executing the given remote command line,
+// with the given additional ssh options
+func (s *sshRunner) command(ctx context.Context, cmdLine string, options ...string) *exec.Cmd {
+	args := append(s.options(), options...)
+	if len(s.port) > 0 {
+		args = append(args, "-p", s.port)
+	}
+	args = append(args, s.destination(), "--", cmdLine)
+	return exec.CommandContext(ctx, "ssh", args...)
+}
+
+// sshPortForwards returns the ssh options forwarding the given TCP ports
+// of the loopback interface of the local host to the remote one
+func sshPortForwards(ports []int) []string {
+	if len(ports) == 0 {
+		return nil
+	}
+	res := []string{"-o", "ExitOnForwardFailure=yes"}
+	for _, p := range ports {
+		res = append(res, "-L", fmt.Sprintf("127.0.0.1:%d:127.0.0.1:%d", p, p))
+	}
+	return res
+}
+
+// output executes a remote command line and returns its stdout
+func (s *sshRunner) output(ctx context.Context, cmdLine string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := s.command(ctx, cmdLine)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ssh command failed: %s (%s)", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// sftp executes a batch of sftp commands
+func (s *sshRunner) sftp(ctx context.Context, batch []string) error {
+	args := s.options()
+	if len(s.port) > 0 {
+		args = append(args, "-P", s.port)
+	}
+	args = append(args, "-b", "-", s.destination())
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sftp", args...)
+	cmd.Stdin = strings.NewReader(strings.Join(batch, "\n") + "\n")
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("sftp command failed: %s (%s)", err.Error(), strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// upload creates the working directory on the remote host and uploads the
+// given files with SFTP, by staging them in a local temporary directory
+func (s *sshRunner) upload(ctx context.Context, files []FileAccessor) (err error) {
+	dirs := []string{shellQuote(s.WorkDir())}
+	var batch []string
+	localDir, err := os.MkdirTemp("", execRunnerWorkDirPrefix)
+	if err != nil {
+		return err
+	}
+	defer func() { err = multierr.Append(err, os.RemoveAll(localDir)) }()
+	for i, f := range files {
+		// if file's name is a relative path, copy it in the workdir.
+		// if file's name is an absolute path, it is copied as-is
+		remotePath := f.Name()
+		if !path.IsAbs(remotePath) {
+			remotePath = path.Join(s.WorkDir(), remotePath)
+		}
+		content, err := f.Content()
+		if err != nil {
+			return err
+		}
+		localPath := filepath.Join(localDir, strconv.Itoa(i))
+		if err := os.WriteFile(localPath, content, os.ModePerm); err != nil {
+			return err
+		}
+		dirs = append(dirs, shellQuote(path.Dir(remotePath)))
+		batch = append(batch, fmt.Sprintf(`put -p "%s" "%s"`, localPath, remotePath))
+	}
+
+	logrus.WithField("host", s.host).Debugf("uploading files through sftp")
+	if _, err := s.output(ctx, "mkdir -p "+strings.Join(dirs, " ")); err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.sftp(ctx, batch)
+}
+
+// download reads the content of a file on the remote host with SFTP
+func (s *sshRunner) download(ctx context.Context, remotePath string) (res []byte, err error) {
+	ctx, cancel := cleanupContext(ctx)
+	defer cancel()
+
+	localDir, err := os.MkdirTemp("", execRunnerWorkDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { err = multierr.Append(err, os.RemoveAll(localDir)) }()
+
+	localPath := filepath.Join(localDir, "file")
+	err = s.sftp(ctx, []string{fmt.Sprintf(`get "%s" "%s"`, remotePath, localPath)})
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(localPath)
+}
+
+// sshProcess is a process running on a remote host through ssh
+type sshProcess struct {
+	*execProcess
+	ctx    context.Context
+	runner *sshRunner
+	sigMu  sync.Mutex
+	sig    syscall.Signal
+}
+
+// lastSignal returns the last signal sent through the process, or zero
+func (p *sshProcess) lastSignal() syscall.Signal {
+	p.sigMu.Lock()
+	defer p.sigMu.Unlock()
+	return p.sig
+}
+
+// release removes the contents of the working directory on the remote
+// host and makes the runner available for other executions
+func (p *sshProcess) release() error {
+	defer p.runner.m.Unlock()
+	ctx, cancel := cleanupContext(p.ctx)
+	defer cancel()
+	_, err := p.runner.output(ctx, "rm -rf "+shellQuote(p.runner.WorkDir())+" "+shellQuote(p.runner.pidFile()))
+	return err
+}
+
+func (p *sshProcess) Signal(sig os.Signal) error {
+	logrus.WithField("host", p.runner.host).WithField("signal", sig).Debugf("signaling process through ssh")
+	if s, ok := sig.(syscall.Signal); ok {
+		p.sigMu.Lock()
+		p.sig = s
+		p.sigMu.Unlock()
+	}
+	_, err := p.runner.output(context.Background(), fmt.Sprintf(`kill -%s "$(cat %s)"`, dockerSignal(sig), shellQuote(p.runner.pidFile())))
+	return err
+}
+
+func (p *sshProcess) Pid() int {
+	out, err := p.runner.output(context.Background(), "cat "+shellQuote(p.runner.pidFile()))
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(out)
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+// shellQuote quotes a string so that it is interpreted as a single
+// word by a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// the SSH runner is tested against the host described by these environment
+// variables (e.g. a local sshd) if they're set, or against a local stand-in
+// of the ssh and sftp clients otherwise
+const (
+	testSSHHostEnv = "FALCO_TESTING_SSH_HOST"
+	testSSHUserEnv = "FALCO_TESTING_SSH_USER"
+	testSSHKeyEnv  = "FALCO_TESTING_SSH_KEY"
+)
+
+// testSSHClients are the local stand-ins of the ssh and sftp clients,
+// which run the remote commands and file transfers on the local host
+var testSSHClients = map[string]string{
+	"ssh": `#!/bin/sh
+while [ "$1" != "--" ]; do shift; done
+/bin/sh -c "$2"
+code=$?
+# like ssh, exit with 255 if the remote command is terminated by a signal
+if [ $code -gt 128 ]; then exit 255; fi
+exit $code
+`,
+	"sftp": `#!/bin/sh
+while read -r cmd args; do
+	eval "set -- $args"
+	if [ "$1" = "-p" ]; then shift; fi
+	case "$cmd" in
+		put|get) cp "$1" "$2" || exit 1 ;;
+		*) exit 1 ;;
+	esac
+done
+`,
+}
+
+// newTestSSHRunner returns a SSH runner for the test host, or for the
+// local host through the stand-in clients if no test host is set
+func newTestSSHRunner(t *testing.T, executable string) Runner {
+	host := os.Getenv(testSSHHostEnv)
+	if len(host) == 0 {
+		dir := t.TempDir()
+		for name, content := range testSSHClients {
+			require.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0755))
+		}
+		t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+		host = "localhost"
+	}
+	runner, err := NewSSHRunner(host, os.Getenv(testSSHUserEnv), os.Getenv(testSSHKeyEnv), executable)
+	require.Nil(t, err)
+	return runner
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, s := range []string{"", "hello world", `it's "quoted"`, "$HOME; exit 1", "a\nb"} {
+		out, err := exec.Command("/bin/sh", "-c", "printf '%s' "+shellQuote(s)).Output()
+		require.Nil(t, err)
+		require.Equal(t, s, string(out))
+	}
+}
+
+func TestSSHPortForwards(t *testing.T) {
+	require.Empty(t, sshPortForwards(nil))
+	require.Equal(t, []string{
+		"-o", "ExitOnForwardFailure=yes",
+		"-L", "127.0.0.1:8765:127.0.0.1:8765",
+		"-L", "127.0.0.1:5060:127.0.0.1:5060",
+	}, sshPortForwards([]int{8765, 5060}))
+}
+
+func TestSSHRunner(t *testing.T) {
+	runner := newTestSSHRunner(t, "/bin/sh")
+
+	// files, env vars, and outputs
+	var stdout, stderr bytes.Buffer
+	p, err := runner.Start(
+		context.Background(),
+		WithStdout(&stdout),
+		WithStderr(&stderr),
+		WithFiles(NewStringFileAccessor("testdir/some-file", "hello")),
+		WithEnvVars(map[string]string{"GREETING": "world's"}),
+		WithArgs("-c", `cat testdir/some-file; echo " $GREETING"; echo err >&2; echo out > out-file`),
+		WithOutputFiles("out-file"),
+	)
+	require.Nil(t, err)
+	require.Nil(t, p.Wait())
+	require.Equal(t, "hello world's\n", stdout.String())
+	require.Equal(t, "err\n", stderr.String())
+	require.Len(t, p.OutputFiles(), 1)
+
+	// exit codes and signals
+	var exitErr *ExitCodeError
+	err = runner.Run(context.Background(), WithArgs("-c", "exit 3"))
+	require.True(t, errors.As(err, &exitErr), "%v", err)
+	require.Equal(t, &ExitCodeError{Code: 3}, exitErr)
+
+	// signals not sent through the process can't be reported
+	err = runner.Run(context.Background(), WithArgs("-c", "kill -9 $$"))
+	require.True(t, errors.As(err, &exitErr), "%v", err)
+	require.Equal(t, &ExitCodeError{Code: 255}, exitErr)
+
+	p, err = runner.Start(context.Background(), WithArgs("-c", "echo ready; exec sleep 60"))
+	require.Nil(t, err)
+	scanner := bufio.NewScanner(p.Stdout())
+	require.True(t, scanner.Scan())
+	require.NotZero(t, p.Pid())
+	require.Nil(t, p.Signal(syscall.SIGTERM))
+	require.True(t, errors.As(p.Wait(), &exitErr))
+	require.Equal(t, &ExitCodeError{Code: 143, Signal: syscall.SIGTERM}, exitErr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p, err = runner.Start(ctx, WithArgs("-c", "echo ready; exec sleep 60"))
+	require.Nil(t, err)
+	scanner = bufio.NewScanner(p.Stdout())
+	require.True(t, scanner.Scan())
+	cancel()
+	require.NotNil(t, p.Wait())
+}
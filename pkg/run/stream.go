@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package run
+
+import (
+	"io"
+	"sync"
+)
+
+// stream is an in-memory output stream that can be written once and read
+// by many concurrent readers. Each reader receives all the data written
+// from the beginning of the stream, and blocks waiting for new data up
+// until the stream is closed.
+type stream struct {
+	m      sync.Mutex
+	c      *sync.Cond
+	data   []byte
+	closed bool
+}
+
+func newStream() *stream {
+	s := &stream{}
+	s.c = sync.NewCond(&s.m)
+	return s
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return 0, io.ErrClosedPipe
+	}
+	s.data = append(s.data, p...)
+	s.c.Broadcast()
+	return len(p), nil
+}
+
+func (s *stream) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.closed = true
+	s.c.Broadcast()
+	return nil
+}
+
+// NewReader returns a new reader reading the stream from its beginning.
+func (s *stream) NewReader() io.Reader {
+	return &streamReader{s: s}
+}
+
+type streamReader struct {
+	s   *stream
+	off int
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	r.s.m.Lock()
+	defer r.s.m.Unlock()
+	for r.off >= len(r.s.data) && !r.s.closed {
+		r.s.c.Wait()
+	}
+	if r.off >= len(r.s.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.s.data[r.off:])
+	r.off += n
+	return n, nil
+}
@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/falcosecurity/testing/pkg/certs"
+	"github.com/falcosecurity/testing/pkg/falco"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+)
+
+// DefaultAddress is the default address on which sinks listen
+const DefaultAddress = "127.0.0.1:0"
+
+type connIDKey struct{}
+
+// HTTPSinkOptions are the options of an HTTP sink
+type HTTPSinkOptions struct {
+	// Address is the address on which the sink listens, which is also
+	// the one in the URL of the sink. Defaults to DefaultAddress if empty,
+	// which is only reachable from the host's network.
+	Address string
+	//
+	// TLS serves HTTPS with a certificate signed by a generated CA
+	TLS bool
+	//
+	// MTLS requires clients to authenticate with a certificate signed by
+	// the generated CA. Implies TLS.
+	MTLS bool
+	//
+	// StatusCode is the status code of the responses of the sink.
+	// Defaults to http.StatusOK if zero.
+	StatusCode int
+}
+
+// HTTPRequest is an HTTP request received by a sink
+type HTTPRequest struct {
+	Time   time.Time
+	Method string
+	Path   string
+	Header http.Header
+	//
+	// Body is the body of the request, decompressed
+	// if it was compressed
+	Body []byte
+	//
+	// Compressed is true if the body of the request was compressed
+	Compressed bool
+	//
+	// TLS is true if the request was received through TLS
+	TLS bool
+	//
+	// ClientCertificate is the common name of the certificate
+	// presented by the client, if any
+	ClientCertificate string
+	//
+	// Conn is the sequence number of the connection through which the
+	// request was received, starting from 1. Requests sharing the
+	// same number were sent with the same kept-alive connection.
+	Conn uint64
+}
+
+// Detections converts the body of the request into a list of Falco alerts.
+func (r *HTTPRequest) Detections() falco.Detections {
+	return falco.ParseDetections(string(r.Body))
+}
+
+// HTTPSink is an in-process HTTP server recording all the requests
+// it receives, which can be used as the destination of the `http_output`
+// output channel of Falco.
+type HTTPSink struct {
+	m        sync.Mutex
+	options  HTTPSinkOptions
+	server   *http.Server
+	listener net.Listener
+	bundle   *certs.Bundle
+	conns    uint64
+	requests []*HTTPRequest
+	changed  chan struct{}
+	served   chan struct{}
+	errorLog *io.PipeWriter
+}
+
+// NewHTTPSink creates an HTTP sink and starts serving requests.
+// Close must be called once the sink is not used anymore.
+func NewHTTPSink(options *HTTPSinkOptions) (*HTTPSink, error) {
+	res := &HTTPSink{changed: make(chan struct{}), served: make(chan struct{})}
+	if options != nil {
+		res.options = *options
+	}
+	if len(res.options.Address) == 0 {
+		res.options.Address = DefaultAddress
+	}
+	if res.options.StatusCode == 0 {
+		res.options.StatusCode = http.StatusOK
+	}
+	res.options.TLS = res.options.TLS || res.options.MTLS
+
+	var err error
+	res.listener, err = net.Listen("tcp", res.options.Address)
+	if err != nil {
+		return nil, err
+	}
+	if res.options.TLS {
+		host, _, _ := net.SplitHostPort(res.listener.Addr().String())
+		var config *tls.Config
+		res.bundle, err = certs.Generate("localhost", host)
+		if err == nil {
+			config, err = res.bundle.ServerTLSConfig(res.options.MTLS)
+		}
+		if err != nil {
+			res.listener.Close()
+			return nil, err
+		}
+		res.listener = tls.NewListener(res.listener, config)
+	}
+
+	res.errorLog = logrus.StandardLogger().WriterLevel(logrus.DebugLevel)
+	res.server = &http.Server{
+		Handler:  http.HandlerFunc(res.handle),
+		ErrorLog: log.New(res.errorLog, "", 0),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connIDKey{}, atomic.AddUint64(&res.conns, 1))
+		},
+	}
+	go func() {
+		defer close(res.served)
+		if err := res.server.Serve(res.listener); err != nil && err != http.ErrServerClosed {
+			logrus.WithError(err).Error("http sink stopped serving")
+		}
+	}()
+	return res, nil
+}
+
+// URL returns the URL of the sink.
+func (s *HTTPSink) URL() string {
+	scheme := "http"
+	if s.options.TLS {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s/", scheme, s.listener.Addr().String())
+}
+
+// Certificates returns the certificates used by the sink for TLS,
+// or nil if TLS is not used.
+func (s *HTTPSink) Certificates() *certs.Bundle {
+	return s.bundle
+}
+
+// MTLS returns true if the sink requires clients to authenticate
+// with a certificate.
+func (s *HTTPSink) MTLS() bool {
+	return s.options.MTLS
+}
+
+// Requests returns all the requests received by the sink so far.
+func (s *HTTPSink) Requests() []*HTTPRequest {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]*HTTPRequest{}, s.requests...)
+}
+
+// Connections returns the number of connections accepted by the sink so far.
+func (s *HTTPSink) Connections() int {
+	return int(atomic.LoadUint64(&s.conns))
+}
+
+// Detections returns all the alerts received by the sink so far.
+func (s *HTTPSink) Detections() falco.Detections {
+	var res falco.Detections
+	for _, r := range s.Requests() {
+		res = append(res, r.Detections()...)
+	}
+	return res
+}
+
+// Wait blocks until the sink receives at least the given amount of
+// requests, or until the context is done.
+func (s *HTTPSink) Wait(ctx context.Context, count int) error {
+	for {
+		s.m.Lock()
+		received, changed := len(s.requests), s.changed
+		s.m.Unlock()
+		if received >= count {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("http sink received %d requests out of %d: %s", received, count, ctx.Err())
+		}
+	}
+}
+
+// Close stops the sink and closes all its connections.
+func (s *HTTPSink) Close() error {
+	err := s.server.Close()
+	<-s.served
+	return multierr.Append(err, s.errorLog.Close())
+}
+
+func (s *HTTPSink) handle(w http.ResponseWriter, r *http.Request) {
+	req := &HTTPRequest{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Header: r.Header.Clone(),
+		TLS:    r.TLS != nil,
+	}
+	if id, ok := r.Context().Value(connIDKey{}).(uint64); ok {
+		req.Conn = id
+	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		req.ClientCertificate = r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
+	var err error
+	req.Body, err = io.ReadAll(r.Body)
+	if err == nil {
+		req.Compressed, req.Body, err = decompress(r.Header.Get("Content-Encoding"), req.Body)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("http sink can't read request body")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.m.Lock()
+	s.requests = append(s.requests, req)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.m.Unlock()
+	w.WriteHeader(s.options.StatusCode)
+}
+
+// decompress decompresses a body with the given content encoding,
+// and returns true if it was compressed
+func decompress(encoding string, body []byte) (bool, []byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return false, body, nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return false, nil, fmt.Errorf("unsupported content encoding '%s'", encoding)
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	defer r.Close()
+	res, err := io.ReadAll(r)
+	return true, res, err
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAlert = `{"hostname":"host","output":"some output","priority":"Warning","rule":"some_rule","source":"syscall","tags":["a","b"],"time":"2023-01-01T00:00:00.000000000Z","output_fields":{"proc.name":"cat"}}`
+
+func post(t *testing.T, client *http.Client, url string, body io.Reader, header map[string]string) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	require.NoError(t, err)
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestHTTPSink(t *testing.T) {
+	t.Parallel()
+	sink, err := NewHTTPSink(nil)
+	require.NoError(t, err)
+	defer sink.Close()
+	assert.True(t, strings.HasPrefix(sink.URL(), "http://127.0.0.1:"))
+	assert.Nil(t, sink.Certificates())
+
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	gw.Write([]byte(testAlert))
+	require.NoError(t, gw.Close())
+
+	client := &http.Client{}
+	post(t, client, sink.URL(), strings.NewReader(testAlert), map[string]string{"User-Agent": "falcosecurity/falco"})
+	post(t, client, sink.URL(), &compressed, map[string]string{"Content-Encoding": "gzip"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, sink.Wait(ctx, 2))
+
+	requests := sink.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, http.MethodPost, requests[0].Method)
+	assert.Equal(t, "/", requests[0].Path)
+	assert.Equal(t, "falcosecurity/falco", requests[0].Header.Get("User-Agent"))
+	assert.False(t, requests[0].Compressed)
+	assert.False(t, requests[0].TLS)
+	assert.True(t, requests[1].Compressed)
+	assert.Equal(t, testAlert, string(requests[1].Body))
+
+	// the client keeps connections alive by default
+	assert.Equal(t, requests[0].Conn, requests[1].Conn)
+	assert.Equal(t, 1, sink.Connections())
+
+	detections := sink.Detections()
+	assert.Equal(t, 2, detections.Count())
+	assert.Equal(t, 2, detections.OfRule("some_rule").OfPriority("WARNING").Count())
+	assert.Equal(t, "cat", detections.First().OutputFields["proc.name"])
+}
+
+func TestHTTPSink_TLS(t *testing.T) {
+	t.Parallel()
+	for _, mtls := range []bool{false, true} {
+		sink, err := NewHTTPSink(&HTTPSinkOptions{TLS: true, MTLS: mtls})
+		require.NoError(t, err)
+		defer sink.Close()
+		assert.True(t, strings.HasPrefix(sink.URL(), "https://127.0.0.1:"))
+		assert.Equal(t, mtls, sink.MTLS())
+		require.NotNil(t, sink.Certificates())
+
+		config, err := sink.Certificates().ClientTLSConfig("127.0.0.1")
+		require.NoError(t, err)
+		if mtls {
+			// clients without a certificate must be rejected
+			_, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				ServerName: "127.0.0.1",
+				RootCAs:    sink.Certificates().CertPool(),
+			}}}).Post(sink.URL(), "application/json", strings.NewReader(testAlert))
+			assert.Error(t, err)
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		post(t, client, sink.URL(), strings.NewReader(testAlert), nil)
+
+		requests := sink.Requests()
+		require.Len(t, requests, 1)
+		assert.True(t, requests[0].TLS)
+		if mtls {
+			assert.Equal(t, "falcosecurity-testing-client", requests[0].ClientCertificate)
+		}
+	}
+}
+
+func TestHTTPSink_Wait(t *testing.T) {
+	t.Parallel()
+	sink, err := NewHTTPSink(&HTTPSinkOptions{StatusCode: http.StatusAccepted})
+	require.NoError(t, err)
+	defer sink.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, sink.Wait(ctx, 1))
+
+	res, err := http.Post(sink.URL(), "text/plain", strings.NewReader("text"))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	require.NoError(t, sink.Wait(context.Background(), 1))
+}
@@ -0,0 +1,499 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/falcosecurity/testing/pkg/falco"
+	"github.com/sirupsen/logrus"
+	"go.uber.org/multierr"
+)
+
+// SyslogDevice is the path of the socket to which Falco sends
+// syslog messages, through the syslog(3) function of libc
+const SyslogDevice = "/dev/log"
+
+// syslogMaxMessageSize is the max size of a received datagram
+const syslogMaxMessageSize = 64 * 1024
+
+// SyslogNetwork is the network on which a syslog sink listens
+type SyslogNetwork string
+
+const (
+	// SyslogUnix receives datagrams on a unix socket, like /dev/log
+	SyslogUnix SyslogNetwork = "unixgram"
+	//
+	// SyslogUDP receives datagrams on a UDP port
+	SyslogUDP SyslogNetwork = "udp"
+	//
+	// SyslogTCP receives newline-delimited or octet-counted
+	// messages on a TCP port
+	SyslogTCP SyslogNetwork = "tcp"
+)
+
+// SyslogFormat is the format of a syslog message
+type SyslogFormat string
+
+const (
+	// RFC3164 is the BSD syslog format, also used by libc
+	RFC3164 SyslogFormat = "rfc3164"
+	//
+	// RFC5424 is the IETF syslog format
+	RFC5424 SyslogFormat = "rfc5424"
+)
+
+// syslogPriorities are the names of the Falco priorities,
+// indexed by their syslog severity
+var syslogPriorities = []string{
+	"Emergency",
+	"Alert",
+	"Critical",
+	"Error",
+	"Warning",
+	"Notice",
+	"Informational",
+	"Debug",
+}
+
+// SeverityToPriority returns the Falco priority mapped to a syslog
+// severity, or an empty string if the severity is not valid.
+func SeverityToPriority(severity int) string {
+	if severity < 0 || severity >= len(syslogPriorities) {
+		return ""
+	}
+	return syslogPriorities[severity]
+}
+
+// PriorityToSeverity returns the syslog severity mapped to a Falco
+// priority, or -1 if the priority is not valid.
+func PriorityToSeverity(priority string) int {
+	p := strings.ToLower(priority)
+	if p == "info" {
+		p = "informational"
+	}
+	for i, name := range syslogPriorities {
+		if strings.ToLower(name) == p {
+			return i
+		}
+	}
+	return -1
+}
+
+// SyslogSinkOptions are the options of a syslog sink
+type SyslogSinkOptions struct {
+	// Network is the network on which the sink listens.
+	// Defaults to SyslogUnix if empty.
+	Network SyslogNetwork
+	//
+	// Address is the address on which the sink listens. For SyslogUnix,
+	// this is the path of the socket and defaults to a path in a new
+	// temporary directory. For SyslogUDP and SyslogTCP, this defaults
+	// to DefaultAddress.
+	Address string
+}
+
+// SyslogMessage is a syslog message received by a sink
+type SyslogMessage struct {
+	Format   SyslogFormat
+	Facility int
+	Severity int
+	Time     time.Time
+	Hostname string
+	AppName  string
+	ProcID   string
+	MsgID    string
+	Message  string
+	//
+	// Raw is the message as it was received
+	Raw string
+}
+
+// Priority returns the Falco priority mapped to the
+// severity of the message.
+func (m *SyslogMessage) Priority() string {
+	return SeverityToPriority(m.Severity)
+}
+
+// Alert converts the message into a Falco alert. The message is parsed
+// both in JSON and text formats, and its priority is set from the
+// severity of the message if it can't be parsed.
+func (m *SyslogMessage) Alert() *falco.Alert {
+	if d := falco.ParseDetections(m.Message); d.Count() == 1 {
+		return d.First()
+	}
+	return &falco.Alert{
+		Time:     m.Time,
+		Priority: m.Priority(),
+		Hostname: m.Hostname,
+		Output:   m.Message,
+	}
+}
+
+// SyslogSink is an in-process syslog receiver recording all the messages
+// it receives, which can be used as the destination of the `syslog_output`
+// output channel of Falco. Falco always sends syslog messages to
+// SyslogDevice, so a sink listening on SyslogUnix can be bound into
+// a Falco container through DockerBind.
+type SyslogSink struct {
+	m        sync.Mutex
+	network  SyslogNetwork
+	address  string
+	tempDir  string
+	conn     net.PacketConn
+	listener net.Listener
+	messages []*SyslogMessage
+	changed  chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSyslogSink creates a syslog sink and starts receiving messages.
+// Close must be called once the sink is not used anymore.
+func NewSyslogSink(options *SyslogSinkOptions) (*SyslogSink, error) {
+	res := &SyslogSink{network: SyslogUnix, changed: make(chan struct{})}
+	if options != nil {
+		if len(options.Network) > 0 {
+			res.network = options.Network
+		}
+		res.address = options.Address
+	}
+
+	var err error
+	switch res.network {
+	case SyslogUnix:
+		if len(res.address) == 0 {
+			res.tempDir, err = os.MkdirTemp("", "falcosecurity-testing-syslog-")
+			if err != nil {
+				return nil, err
+			}
+			res.address = filepath.Join(res.tempDir, "log")
+		}
+		res.conn, err = net.ListenPacket(string(res.network), res.address)
+		if err == nil {
+			// note: the socket must be writable by Falco, which
+			// may run with a different user (e.g. in a container)
+			err = os.Chmod(res.address, 0777)
+		}
+	case SyslogUDP:
+		if len(res.address) == 0 {
+			res.address = DefaultAddress
+		}
+		res.conn, err = net.ListenPacket(string(res.network), res.address)
+	case SyslogTCP:
+		if len(res.address) == 0 {
+			res.address = DefaultAddress
+		}
+		res.listener, err = net.Listen(string(res.network), res.address)
+	default:
+		err = fmt.Errorf("unknown syslog network '%s'", res.network)
+	}
+	if err != nil {
+		return nil, multierr.Append(err, res.Close())
+	}
+
+	res.wg.Add(1)
+	if res.conn != nil {
+		res.address = res.conn.LocalAddr().String()
+		go res.readPackets()
+	} else {
+		res.address = res.listener.Addr().String()
+		go res.accept()
+	}
+	return res, nil
+}
+
+// Network returns the network on which the sink listens.
+func (s *SyslogSink) Network() SyslogNetwork {
+	return s.network
+}
+
+// Address returns the address on which the sink listens.
+func (s *SyslogSink) Address() string {
+	return s.address
+}
+
+// DockerBind returns a Docker bind mounting the socket of the sink as
+// SyslogDevice in a container (see DockerRunnerOptions in the run package).
+// Returns an empty string if the sink doesn't listen on SyslogUnix.
+func (s *SyslogSink) DockerBind() string {
+	if s.network != SyslogUnix {
+		return ""
+	}
+	return s.address + ":" + SyslogDevice
+}
+
+// Messages returns all the messages received by the sink so far.
+func (s *SyslogSink) Messages() []*SyslogMessage {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return append([]*SyslogMessage{}, s.messages...)
+}
+
+// Detections returns all the messages received by the sink so
+// far, converted into Falco alerts.
+func (s *SyslogSink) Detections() falco.Detections {
+	var res falco.Detections
+	for _, m := range s.Messages() {
+		res = append(res, m.Alert())
+	}
+	return res
+}
+
+// Wait blocks until the sink receives at least the given amount of
+// messages, or until the context is done.
+func (s *SyslogSink) Wait(ctx context.Context, count int) error {
+	for {
+		s.m.Lock()
+		received, changed := len(s.messages), s.changed
+		s.m.Unlock()
+		if received >= count {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("syslog sink received %d messages out of %d: %s", received, count, ctx.Err())
+		}
+	}
+}
+
+// Close stops the sink and removes all its resources.
+func (s *SyslogSink) Close() (err error) {
+	if s.conn != nil {
+		err = multierr.Append(err, s.conn.Close())
+	}
+	if s.listener != nil {
+		err = multierr.Append(err, s.listener.Close())
+	}
+	s.wg.Wait()
+	if len(s.tempDir) > 0 {
+		err = multierr.Append(err, os.RemoveAll(s.tempDir))
+	}
+	return err
+}
+
+func (s *SyslogSink) record(raw string) {
+	msg, err := ParseSyslogMessage(raw)
+	if err != nil {
+		logrus.WithError(err).WithField("message", raw).Warn("syslog sink can't parse message")
+		return
+	}
+	s.m.Lock()
+	s.messages = append(s.messages, msg)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.m.Unlock()
+}
+
+func (s *SyslogSink) readPackets() {
+	defer s.wg.Done()
+	buf := make([]byte, syslogMaxMessageSize)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		s.record(string(buf[:n]))
+	}
+}
+
+func (s *SyslogSink) accept() {
+	defer s.wg.Done()
+	var conns sync.WaitGroup
+	defer conns.Wait()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conns.Add(1)
+		go func() {
+			defer conns.Done()
+			defer conn.Close()
+			s.readStream(conn)
+		}()
+	}
+}
+
+// readStream reads messages from a stream framed either with octet
+// counting or with trailing newlines, as described by RFC6587
+func (s *SyslogSink) readStream(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		first, err := br.Peek(1)
+		if err != nil {
+			return
+		}
+		var raw string
+		if first[0] >= '0' && first[0] <= '9' {
+			lenStr, err := br.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, err := strconv.Atoi(strings.TrimSpace(lenStr))
+			if err != nil {
+				logrus.WithError(err).Warn("syslog sink received invalid octet count")
+				return
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return
+			}
+			raw = string(buf)
+		} else {
+			raw, err = br.ReadString('\n')
+			if err != nil && len(raw) == 0 {
+				return
+			}
+		}
+		if raw = strings.TrimRight(raw, "\r\n\x00"); len(raw) > 0 {
+			s.record(raw)
+		}
+	}
+}
+
+// ParseSyslogMessage parses a syslog message either in RFC3164 or RFC5424
+// format. For RFC3164, the hostname is optional, as messages sent locally
+// by libc don't include it.
+func ParseSyslogMessage(raw string) (*SyslogMessage, error) {
+	res := &SyslogMessage{Raw: raw}
+	msg := strings.TrimRight(raw, "\r\n\x00")
+	if !strings.HasPrefix(msg, "<") {
+		return nil, fmt.Errorf("missing syslog priority")
+	}
+	end := strings.Index(msg, ">")
+	if end < 2 || end > 4 {
+		return nil, fmt.Errorf("invalid syslog priority")
+	}
+	pri, err := strconv.Atoi(msg[1:end])
+	if err != nil || pri > 191 {
+		return nil, fmt.Errorf("invalid syslog priority '%s'", msg[1:end])
+	}
+	res.Facility, res.Severity = pri/8, pri%8
+	msg = msg[end+1:]
+
+	if strings.HasPrefix(msg, "1 ") {
+		return res, parseRFC5424(res, msg[2:])
+	}
+	return res, parseRFC3164(res, msg)
+}
+
+// nilValue returns an empty string if the given RFC5424 field is nil
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+func parseRFC5424(res *SyslogMessage, msg string) error {
+	res.Format = RFC5424
+	fields := strings.SplitN(msg, " ", 6)
+	if len(fields) < 5 {
+		return fmt.Errorf("invalid rfc5424 syslog message")
+	}
+	if ts := nilValue(fields[0]); len(ts) > 0 {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return err
+		}
+		res.Time = t
+	}
+	res.Hostname = nilValue(fields[1])
+	res.AppName = nilValue(fields[2])
+	res.ProcID = nilValue(fields[3])
+	res.MsgID = nilValue(fields[4])
+	if len(fields) < 6 {
+		return nil
+	}
+
+	// skip the structured data, which is either nil or a sequence
+	// of bracketed elements in which "]" can be escaped
+	rest := fields[5]
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else {
+		for strings.HasPrefix(rest, "[") {
+			i := 1
+			for ; i < len(rest) && rest[i] != ']'; i++ {
+				if rest[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(rest) {
+				return fmt.Errorf("invalid rfc5424 structured data")
+			}
+			rest = rest[i+1:]
+		}
+	}
+	res.Message = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+	return nil
+}
+
+func parseRFC3164(res *SyslogMessage, msg string) error {
+	res.Format = RFC3164
+
+	// note: some senders (e.g. the Go standard library) use an RFC3339
+	// timestamp in place of the one defined by RFC3164
+	const stampLen = len(time.Stamp)
+	if i := strings.Index(msg, " "); i > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, msg[:i]); err == nil {
+			res.Time = t
+			msg = msg[i+1:]
+		}
+	}
+	if res.Time.IsZero() {
+		if len(msg) < stampLen+1 {
+			return fmt.Errorf("invalid rfc3164 syslog message")
+		}
+		t, err := time.ParseInLocation(time.Stamp, msg[:stampLen], time.Local)
+		if err != nil {
+			return err
+		}
+		res.Time = t.AddDate(time.Now().Year(), 0, 0)
+		msg = strings.TrimPrefix(msg[stampLen:], " ")
+	}
+
+	// the hostname is present only if the first word is not the tag
+	if i := strings.Index(msg, " "); i > 0 && !strings.HasSuffix(msg[:i], ":") {
+		res.Hostname = msg[:i]
+		msg = msg[i+1:]
+	}
+	if i := strings.Index(msg, ": "); i > 0 && !strings.Contains(msg[:i], " ") {
+		tag := msg[:i]
+		if j := strings.Index(tag, "["); j > 0 && strings.HasSuffix(tag, "]") {
+			res.ProcID = tag[j+1 : len(tag)-1]
+			tag = tag[:j]
+		}
+		res.AppName = tag
+		msg = msg[i+2:]
+	}
+	res.Message = msg
+	return nil
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package sinks
+
+import (
+	"context"
+	"log/syslog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSyslogMessage(t *testing.T) {
+	t.Parallel()
+	t.Run("rfc3164-local", func(t *testing.T) {
+		t.Parallel()
+		m, err := ParseSyslogMessage("<12>Oct 11 22:14:15 falco: " + testAlert)
+		require.NoError(t, err)
+		assert.Equal(t, RFC3164, m.Format)
+		assert.Equal(t, 1, m.Facility)
+		assert.Equal(t, 4, m.Severity)
+		assert.Equal(t, "Warning", m.Priority())
+		assert.Equal(t, time.October, m.Time.Month())
+		assert.Equal(t, 22, m.Time.Hour())
+		assert.Empty(t, m.Hostname)
+		assert.Equal(t, "falco", m.AppName)
+		assert.Equal(t, testAlert, m.Message)
+		assert.Equal(t, "some_rule", m.Alert().Rule)
+		assert.Equal(t, "Warning", m.Alert().Priority)
+	})
+	t.Run("rfc3164-remote", func(t *testing.T) {
+		t.Parallel()
+		m, err := ParseSyslogMessage("<34>Oct  1 22:14:15 myhost falco[42]: some text\n")
+		require.NoError(t, err)
+		assert.Equal(t, 4, m.Facility)
+		assert.Equal(t, 2, m.Severity)
+		assert.Equal(t, "myhost", m.Hostname)
+		assert.Equal(t, "falco", m.AppName)
+		assert.Equal(t, "42", m.ProcID)
+		assert.Equal(t, "some text", m.Message)
+		assert.Equal(t, "Critical", m.Alert().Priority)
+		assert.Equal(t, "some text", m.Alert().Output)
+	})
+	t.Run("rfc5424", func(t *testing.T) {
+		t.Parallel()
+		m, err := ParseSyslogMessage(`<165>1 2003-10-11T22:14:15.003Z myhost falco 42 ID47 [a b="\]"][c d="e"] some text`)
+		require.NoError(t, err)
+		assert.Equal(t, RFC5424, m.Format)
+		assert.Equal(t, 20, m.Facility)
+		assert.Equal(t, 5, m.Severity)
+		assert.Equal(t, 2003, m.Time.Year())
+		assert.Equal(t, "myhost", m.Hostname)
+		assert.Equal(t, "falco", m.AppName)
+		assert.Equal(t, "42", m.ProcID)
+		assert.Equal(t, "ID47", m.MsgID)
+		assert.Equal(t, "some text", m.Message)
+	})
+	t.Run("rfc5424-nil", func(t *testing.T) {
+		t.Parallel()
+		m, err := ParseSyslogMessage(`<14>1 - - - - - -`)
+		require.NoError(t, err)
+		assert.True(t, m.Time.IsZero())
+		assert.Empty(t, m.Hostname)
+		assert.Empty(t, m.Message)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		for _, raw := range []string{"", "no priority", "<abc>msg", "<200>Oct 11 22:14:15 msg", "<12>1 2003"} {
+			_, err := ParseSyslogMessage(raw)
+			assert.Error(t, err, raw)
+		}
+	})
+}
+
+func TestSeverityPriorityMapping(t *testing.T) {
+	t.Parallel()
+	for i := 0; i < 8; i++ {
+		assert.Equal(t, i, PriorityToSeverity(SeverityToPriority(i)))
+	}
+	assert.Equal(t, 6, PriorityToSeverity("INFO"))
+	assert.Equal(t, -1, PriorityToSeverity("unknown"))
+	assert.Empty(t, SeverityToPriority(8))
+}
+
+func TestSyslogSink(t *testing.T) {
+	t.Parallel()
+	t.Run("unix", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		sink, err := NewSyslogSink(nil)
+		require.NoError(t, err)
+		defer sink.Close()
+		assert.Equal(t, SyslogUnix, sink.Network())
+		assert.True(t, strings.HasSuffix(sink.DockerBind(), ":"+SyslogDevice))
+
+		// note: this sends messages in the same format of libc
+		w, err := syslog.Dial(string(sink.Network()), sink.Address(), syslog.LOG_USER, "falco")
+		require.NoError(t, err)
+		defer w.Close()
+		require.NoError(t, w.Warning(testAlert))
+		require.NoError(t, w.Err("some text"))
+		require.NoError(t, sink.Wait(ctx, 2))
+
+		messages := sink.Messages()
+		require.Len(t, messages, 2)
+		assert.Equal(t, "Warning", messages[0].Priority())
+		assert.Equal(t, "Error", messages[1].Priority())
+		assert.Equal(t, "falco", messages[1].AppName)
+		assert.Equal(t, 1, sink.Detections().OfRule("some_rule").Count())
+		assert.Equal(t, 1, sink.Detections().OfPriority("Error").Count())
+	})
+	t.Run("udp", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		sink, err := NewSyslogSink(&SyslogSinkOptions{Network: SyslogUDP})
+		require.NoError(t, err)
+		defer sink.Close()
+		assert.Empty(t, sink.DockerBind())
+
+		w, err := syslog.Dial(string(sink.Network()), sink.Address(), syslog.LOG_LOCAL0, "falco")
+		require.NoError(t, err)
+		defer w.Close()
+		require.NoError(t, w.Notice("some text"))
+		require.NoError(t, sink.Wait(ctx, 1))
+		assert.Equal(t, 16, sink.Messages()[0].Facility)
+		assert.Equal(t, "Notice", sink.Messages()[0].Priority())
+	})
+	t.Run("tcp", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		sink, err := NewSyslogSink(&SyslogSinkOptions{Network: SyslogTCP})
+		require.NoError(t, err)
+		defer sink.Close()
+
+		// send both newline-delimited and octet-counted messages
+		conn, err := net.Dial("tcp", sink.Address())
+		require.NoError(t, err)
+		octetCounted := "<15>1 - host falco - - - some text"
+		_, err = conn.Write([]byte("<14>Oct 11 22:14:15 falco: first\n" + "34 " + octetCounted))
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+		require.NoError(t, sink.Wait(ctx, 2))
+
+		messages := sink.Messages()
+		require.Len(t, messages, 2)
+		assert.Equal(t, "first", messages[0].Message)
+		assert.Equal(t, RFC5424, messages[1].Format)
+		assert.Equal(t, "Debug", messages[1].Priority())
+	})
+	t.Run("unknown", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewSyslogSink(&SyslogSinkOptions{Network: "sctp"})
+		assert.Error(t, err)
+	})
+}
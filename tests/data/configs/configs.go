@@ -19,150 +19,103 @@ limitations under the License.
 package configs
 
 import (
+	"github.com/falcosecurity/testing/pkg/falco"
 	"github.com/falcosecurity/testing/pkg/run"
 )
 
 var EmptyConfig = run.NewStringFileAccessor("empty_config.yaml", "")
 
-var DropsAlert = run.NewStringFileAccessor(
+// dropsConfig creates a config file used for testing the syscall event drops
+// behavior, by applying an override on top of a common base
+func dropsConfig(name string, override *falco.Config) run.FileAccessor {
+	base := &falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{
+			Rate:          falco.Float(.03333),
+			MaxBurst:      falco.Int(10),
+			SimulateDrops: falco.Bool(true),
+		},
+		StdoutOutput: &falco.OutputConfig{Enabled: falco.Bool(true)},
+		LogStderr:    falco.Bool(true),
+	}
+	config, err := base.Merge(override)
+	if err != nil {
+		panic(err.Error())
+	}
+	return mustConfigFile(name, config)
+}
+
+func mustConfigFile(name string, config *falco.Config) run.FileAccessor {
+	res, err := config.File(name)
+	if err != nil {
+		panic(err.Error())
+	}
+	return res
+}
+
+var DropsAlert = dropsConfig(
 	"drops_alert.yaml",
-	`
-syscall_event_drops:
-  actions:
-    - alert
-  rate: .03333
-  max_burst: 10
-  simulate_drops: true
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{Actions: []string{"alert"}},
+	},
 )
 
-var DropsExit = run.NewStringFileAccessor(
+var DropsExit = dropsConfig(
 	"drops_exit.yaml",
-	`
-syscall_event_drops:
-  actions:
-    - exit
-  rate: .03333
-  max_burst: 10
-  simulate_drops: true
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{Actions: []string{"exit"}},
+	},
 )
 
-var DropsIgnore = run.NewStringFileAccessor(
+var DropsIgnore = dropsConfig(
 	"drops_ignore.yaml",
-	`
-syscall_event_drops:
-  actions:
-    - ignore
-  rate: .03333
-  max_burst: 10
-  simulate_drops: true
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{Actions: []string{"ignore"}},
+	},
 )
 
-var DropsIgnoreLog = run.NewStringFileAccessor(
+var DropsIgnoreLog = dropsConfig(
 	"drops_ignore_log.yaml",
-	`
-syscall_event_drops:
-  actions:
-    - ignore
-    - log
-  rate: .03333
-  max_burst: 10
-  simulate_drops: true
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{Actions: []string{"ignore", "log"}},
+	},
 )
 
-var DropsLog = run.NewStringFileAccessor(
+var DropsLog = dropsConfig(
 	"drops_log.yaml",
-	`
-syscall_event_drops:
-  actions:
-    - log
-  rate: .03333
-  max_burst: 10
-  simulate_drops: true
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-
-log_level: debug
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{Actions: []string{"log"}},
+		LogLevel:          "debug",
+	},
 )
 
-var DropsNone = run.NewStringFileAccessor(
+var DropsNone = dropsConfig(
 	"drops_none.yaml",
-	`
-syscall_event_drops:
-  actions:
-    - log
-  rate: .03333
-  max_burst: 10
-  simulate_drops: false
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{
+			Actions:       []string{"log"},
+			SimulateDrops: falco.Bool(false),
+		},
+	},
 )
 
-var DropsThresholdNeg = run.NewStringFileAccessor(
+var DropsThresholdNeg = dropsConfig(
 	"drops_threshold_neg.yaml",
-	`
-syscall_event_drops:
-  threshold: -1
-  actions:
-    - ignore
-  rate: .03333
-  max_burst: 10
-  simulate_drops: true
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{
+			Threshold: falco.Float(-1),
+			Actions:   []string{"ignore"},
+		},
+	},
 )
 
-var DropsThresholdOor = run.NewStringFileAccessor(
+var DropsThresholdOor = dropsConfig(
 	"drops_threshold_oor.yaml",
-	`
-syscall_event_drops:
-  threshold: 1.1
-  actions:
-    - ignore
-  rate: .03333
-  max_burst: 10
-  simulate_drops: true
-
-stdout_output:
-  enabled: true
-
-log_stderr: true
-`,
+	&falco.Config{
+		SyscallEventDrops: &falco.SyscallEventDropsConfig{
+			Threshold: falco.Float(1.1),
+			Actions:   []string{"ignore"},
+		},
+	},
 )
 
 var FileOutput = run.NewStringFileAccessor(
@@ -422,25 +375,19 @@ program_output:
 `,
 )
 
-var RuleMatchingFirst = run.NewStringFileAccessor(
+var RuleMatchingFirst = mustConfigFile(
 	"rule_matching_first.yaml",
-	`
-rule_matching: first
-  `,
+	&falco.Config{RuleMatching: "first"},
 )
 
-var RuleMatchingAll = run.NewStringFileAccessor(
+var RuleMatchingAll = mustConfigFile(
 	"rule_matching_all.yaml",
-	`
-rule_matching: all
-  `,
+	&falco.Config{RuleMatching: "all"},
 )
 
-var RuleMatchingWrongValue = run.NewStringFileAccessor(
+var RuleMatchingWrongValue = mustConfigFile(
 	"rule_matching_wrong_value.yaml",
-	`
-rule_matching: test
-  `,
+	&falco.Config{RuleMatching: "test"},
 )
 
 var MetricsEnabled = run.NewStringFileAccessor(
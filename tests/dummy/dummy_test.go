@@ -8,19 +8,17 @@ import (
 	"github.com/falcosecurity/testing/tests/data/rules"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"net/http"
-	"sync"
 	"testing"
 	"time"
 )
 
-func runFalcoWithDummy(t *testing.T, r run.Runner, opts ...falco.TestOption) *falco.TestOutput {
+func startFalcoWithDummy(t *testing.T, r run.Runner, opts ...falco.TestOption) *falco.TestProcess {
 	config, err := falco.NewPluginConfig(
 		"plugin-config.yaml",
 		&falco.PluginConfigInfo{
 			Name:       "dummy",
 			Library:    plugins.DummyPlugin.Name(),
-			OpenParams: `'{"start": 1, "maxEvents": 2000000000}'`,
+			OpenParams: `{"start": 1, "maxEvents": 2000000000}`,
 		},
 	)
 	require.Nil(t, err)
@@ -30,33 +28,32 @@ func runFalcoWithDummy(t *testing.T, r run.Runner, opts ...falco.TestOption) *fa
 		falco.WithExtraFiles(plugins.DummyPlugin),
 	}
 	options = append(options, opts...)
-	return falco.Test(r, options...)
+	return falco.Start(r, options...)
 }
 
 func TestDummy_PrometheusMetrics(t *testing.T) {
-	var (
-		wg         sync.WaitGroup
-		metricsErr error
-	)
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
-		time.Sleep(2 * time.Second)
-		_, metricsErr = http.Get("http://127.0.0.1:8765/metrics")
-	}()
-
-	falcoRes := runFalcoWithDummy(t,
+	t.Parallel()
+	falcoProc := startFalcoWithDummy(t,
 		tests.NewFalcoExecutableRunner(t),
 		falco.WithPrometheusMetrics(),
 		falco.WithRules(rules.SingleRule),
 		falco.WithStopAfter(5*time.Second),
 		falco.WithArgs("-o", "engine.kind=nodriver"),
+		falco.WithReadyWhen(falco.WebserverEndpointUp(falco.MetricsPath)),
 	)
+	assert.NoError(t, falcoProc.Err())
+
+	falcoRes := falcoProc.Wait()
 	assert.NoError(t, falcoRes.Err(), "%s", falcoRes.Stderr())
 	assert.Equal(t, 0, falcoRes.ExitCode())
-
-	wg.Wait()
-
-	assert.NoError(t, metricsErr)
+	scrapes := falcoRes.Metrics()
+	require.GreaterOrEqual(t, len(scrapes), 2)
+	assert.True(t, scrapes.Last().Has("falcosecurity_falco_version_info"))
+	// no alert must be dropped while the dummy plugin produces events
+	const drops = "falcosecurity_falco_outputs_queue_num_drops_total"
+	_, ok := scrapes.First().Value(drops, nil)
+	require.True(t, ok, "metric %s not found in the first scrape", drops)
+	_, ok = scrapes.Last().Value(drops, nil)
+	require.True(t, ok, "metric %s not found in the last scrape", drops)
+	assert.Equal(t, float64(0), scrapes.Delta(drops, nil))
 }
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package tests
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/falcosecurity/testing/pkg/falco"
+)
+
+var falcoEngines = engineKindsString(falco.EngineKinds)
+
+func init() {
+	flag.StringVar(&falcoEngines, "falco-engines", falcoEngines, "Comma-separated list of the Falco engine kinds on which engine matrix tests are run")
+}
+
+// Engine is a Falco engine on which a test can be run
+type Engine struct {
+	Kind    falco.EngineKind
+	Options *falco.EngineOptions
+}
+
+// Option returns a test option for running Falco with the engine.
+func (e Engine) Option() falco.TestOption {
+	return falco.WithEngine(e.Kind, e.Options)
+}
+
+// RunEngineMatrix runs the given test body as a subtest for each of the
+// given engines. Engines that are not enabled through the `-falco-engines`
+// flag are skipped. Engines that are not supported in the current
+// environment are skipped too, unless the flag is set explicitly, in which
+// case the subtest fails.
+func RunEngineMatrix(t *testing.T, engines []Engine, body func(t *testing.T, e Engine)) {
+	for _, e := range engines {
+		e := e
+		t.Run(string(e.Kind), func(t *testing.T) {
+			if !engineEnabled(e.Kind) {
+				t.Skipf("skipping engine '%s': not enabled with -falco-engines", e.Kind)
+			}
+			if reason := engineSkipReason(t, e); len(reason) > 0 {
+				if isFlagSet("falco-engines") {
+					t.Fatalf("engine '%s' is enabled with -falco-engines but can't be tested: %s", e.Kind, reason)
+				}
+				t.Skipf("skipping engine '%s': %s", e.Kind, reason)
+			}
+			body(t, e)
+		})
+	}
+}
+
+// engineEnabled returns true if the given engine kind is enabled
+// through the `-falco-engines` flag
+func engineEnabled(kind falco.EngineKind) bool {
+	for _, k := range strings.Split(falcoEngines, ",") {
+		if strings.TrimSpace(k) == string(kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFlagSet returns true if the flag with the given name
+// is set in the command line
+func isFlagSet(name string) (res bool) {
+	flag.Visit(func(f *flag.Flag) {
+		res = res || f.Name == name
+	})
+	return res
+}
+
+// engineSkipReason returns a non-empty reason if the given engine
+// can't be tested in the current environment
+func engineSkipReason(t *testing.T, e Engine) string {
+	opts := e.Options
+	if opts == nil {
+		opts = &falco.EngineOptions{}
+	}
+	switch e.Kind {
+	case falco.EngineKmod, falco.EngineEbpf, falco.EngineModernEbpf:
+		if !IsRootUser(t) {
+			return "requires root privileges"
+		}
+	}
+	switch e.Kind {
+	case falco.EngineEbpf:
+		probe := opts.Probe
+		if len(probe) == 0 {
+			home, _ := os.UserHomeDir()
+			probe = filepath.Join(home, ".falco", "falco-bpf.o")
+		}
+		if _, err := os.Stat(probe); err != nil {
+			return "eBPF probe not found at " + probe
+		}
+	case falco.EngineModernEbpf:
+		if _, err := os.Stat("/sys/kernel/btf/vmlinux"); err != nil {
+			return "kernel does not expose BTF information"
+		}
+	case falco.EngineReplay:
+		if opts.CaptureFile == nil {
+			return "no capture file provided"
+		}
+	case falco.EngineGvisor:
+		if len(opts.GvisorConfig) == 0 {
+			return "no gVisor config provided"
+		}
+		if _, err := exec.LookPath("runsc"); err != nil {
+			return "runsc not found in PATH"
+		}
+	case falco.EnginePlugin:
+		if IsStaticFalcoExecutable() {
+			return "plugins are not supported with static Falco builds"
+		}
+	}
+	return ""
+}
+
+func engineKindsString(kinds []falco.EngineKind) string {
+	var res []string
+	for _, k := range kinds {
+		res = append(res, string(k))
+	}
+	return strings.Join(res, ",")
+}
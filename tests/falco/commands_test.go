@@ -19,7 +19,6 @@ limitations under the License.
 package testfalco
 
 import (
-	"regexp"
 	"strings"
 	"testing"
 
@@ -71,16 +70,15 @@ func TestFalco_Cmd_Version(t *testing.T) {
 		// - (commit hash) -> e999e61fa8f57ca8e9590e4c108fd4a12459ec48
 		// - (release) -> 6.0.1+driver
 		// - (release-rc) -> 6.0.1-rc1+driver
-		assert.Regexp(t, regexp.MustCompile(
-			`Falco version:[\s]+`+semVerRegex+`[\s]+`+
-				`Libs version:[\s]+(`+semVerRegex+`|`+commitHashRegex+`)[\s]+`+
-				`Plugin API:[\s]+`+tagRegex+`[\s]+`+
-				`Engine:[\s]+`+tagRegex+`[\s]+`+
-				`Driver:[\s]+`+
-				`API version:[\s]+`+tagRegex+`[\s]+`+
-				`Schema version:[\s]+`+tagRegex+`[\s]+`+
-				`Default driver:[\s]+(`+semVerRegex+`|`+commitHashRegex+`)[\s]*`),
-			res.Stdout())
+		version := res.Version()
+		require.NotNil(t, version)
+		assert.Regexp(t, `^`+semVerRegex+`$`, version.FalcoVersion)
+		assert.Regexp(t, `^(`+semVerRegex+`|`+commitHashRegex+`)$`, version.LibsVersion)
+		assert.Regexp(t, `^`+tagRegex, version.PluginAPIVersion)
+		assert.Regexp(t, `^`+tagRegex, version.EngineVersionSemver)
+		assert.Regexp(t, `^`+tagRegex, version.DriverAPIVersion)
+		assert.Regexp(t, `^`+tagRegex, version.DriverSchemaVersion)
+		assert.Regexp(t, `^(`+semVerRegex+`|`+commitHashRegex+`)$`, version.DefaultDriverVersion)
 	})
 	t.Run("json-output", func(t *testing.T) {
 		t.Parallel()
@@ -88,16 +86,17 @@ func TestFalco_Cmd_Version(t *testing.T) {
 			falco.WithArgs("--version"),
 			falco.WithOutputJSON(),
 		)
-		out := res.StdoutJSON()
 		assert.NoError(t, res.Err(), "%s", res.Stderr())
 		assert.Equal(t, res.ExitCode(), 0)
-		assert.Contains(t, out, "default_driver_version")
-		assert.Contains(t, out, "driver_api_version")
-		assert.Contains(t, out, "driver_schema_version")
-		assert.Contains(t, out, "engine_version")
-		assert.Contains(t, out, "falco_version")
-		assert.Contains(t, out, "libs_version")
-		assert.Contains(t, out, "plugin_api_version")
+		version := res.Version()
+		require.NotNil(t, version)
+		assert.NotEmpty(t, version.DefaultDriverVersion)
+		assert.NotEmpty(t, version.DriverAPIVersion)
+		assert.NotEmpty(t, version.DriverSchemaVersion)
+		assert.NotEmpty(t, version.EngineVersion)
+		assert.NotEmpty(t, version.FalcoVersion)
+		assert.NotEmpty(t, version.LibsVersion)
+		assert.NotEmpty(t, version.PluginAPIVersion)
 	})
 }
 
@@ -113,23 +112,23 @@ func TestFalco_Cmd_ListPlugins(t *testing.T) {
 	)
 	assert.NoError(t, res.Err(), "%s", res.Stderr())
 	assert.Equal(t, res.ExitCode(), 0)
-	assert.Regexp(t, regexp.MustCompile(
-		`2 Plugins Loaded:[\s]+`+
-			`Name: cloudtrail[\s]+`+
-			`Description: .*[\s]+`+
-			`Contact: .*[\s]+`+
-			`Version: .*[\s]+`+
-			`Capabilities:[\s]+`+
-			`- Event Sourcing \(ID=2, source='aws_cloudtrail'\)[\s]+`+
-			`- Field Extraction[\s]+`+
-			`Name: json[\s]+`+
-			`Description: .*[\s]+`+
-			`Contact: .*[\s]+`+
-			`Version: .*[\s]+`+
-			`Capabilities:[\s]+`+
-			`[\s]+`+
-			`- Field Extraction`),
-		res.Stdout())
+	plugins := res.PluginList()
+	require.Len(t, plugins, 2)
+	assert.Equal(t, "cloudtrail", plugins[0].Name)
+	assert.NotEmpty(t, plugins[0].Description)
+	assert.NotEmpty(t, plugins[0].Contact)
+	assert.NotEmpty(t, plugins[0].Version)
+	assert.Equal(t, falco.PluginCapabilities{
+		EventSourcing:   true,
+		FieldExtraction: true,
+		EventSourceID:   2,
+		EventSource:     "aws_cloudtrail",
+	}, plugins[0].Capabilities)
+	assert.Equal(t, "json", plugins[1].Name)
+	assert.NotEmpty(t, plugins[1].Description)
+	assert.NotEmpty(t, plugins[1].Contact)
+	assert.NotEmpty(t, plugins[1].Version)
+	assert.Equal(t, falco.PluginCapabilities{FieldExtraction: true}, plugins[1].Capabilities)
 }
 
 func TestFalco_Cmd_PluginInfo(t *testing.T) {
@@ -143,17 +142,45 @@ func TestFalco_Cmd_PluginInfo(t *testing.T) {
 	)
 	assert.NoError(t, res.Err(), "%s", res.Stderr())
 	assert.Equal(t, res.ExitCode(), 0)
-	assert.Regexp(t, regexp.MustCompile(
-		`Name: cloudtrail[\s]+`+
-			`Description: .*[\s]+`+
-			`Contact: .*[\s]+`+
-			`Version: .*[\s]+`+
-			`Capabilities:[\s]+`+
-			`- Event Sourcing \(ID=2, source='aws_cloudtrail'\)[\s]+`+
-			`- Field Extraction[\s]+`+
-			`Init config schema type: JSON[\s]+.*[\s]+`+
-			`No suggested open params available.*`),
-		res.Stdout())
+	info := res.PluginInfo()
+	require.NotNil(t, info)
+	assert.Equal(t, "cloudtrail", info.Name)
+	assert.NotEmpty(t, info.Description)
+	assert.NotEmpty(t, info.Contact)
+	assert.NotEmpty(t, info.Version)
+	assert.Equal(t, falco.PluginCapabilities{
+		EventSourcing:   true,
+		FieldExtraction: true,
+		EventSourceID:   2,
+		EventSource:     "aws_cloudtrail",
+	}, info.Capabilities)
+	assert.Equal(t, "JSON", info.InitSchemaType)
+	assert.NotEmpty(t, info.InitSchema)
+	assert.Empty(t, info.OpenParams)
+}
+
+func TestFalco_Cmd_ListPluginFields(t *testing.T) {
+	t.Parallel()
+	checkConfig(t)
+	checkNotStaticExecutable(t)
+	res := falco.Test(
+		tests.NewFalcoExecutableRunner(t),
+		falco.WithArgs("--list=aws_cloudtrail"),
+		falco.WithArgs("-o", "load_plugins[0]=cloudtrail"),
+		falco.WithArgs("-o", "load_plugins[1]=json"),
+	)
+	assert.NoError(t, res.Err(), "%s", res.Stderr())
+	assert.Equal(t, res.ExitCode(), 0)
+	fields := res.PluginFields("cloudtrail")
+	require.NotEmpty(t, fields)
+	names := make(map[string]bool)
+	for _, f := range fields {
+		assert.NotEmpty(t, f.Desc, "field %s has no description", f.Name)
+		names[f.Name] = true
+	}
+	assert.True(t, names["ct.id"])
+	assert.True(t, names["ct.name"])
+	assert.NotEmpty(t, res.PluginFields("json"))
 }
 
 func TestFalco_Print_IgnoredEvents(t *testing.T) {
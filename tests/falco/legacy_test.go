@@ -39,17 +39,10 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"errors"
-	"os"
-	"sync"
 	"testing"
 	"time"
 
-	grpcOutputs "github.com/falcosecurity/client-go/pkg/api/outputs"
-	"github.com/falcosecurity/client-go/pkg/client"
-
 	"github.com/falcosecurity/testing/pkg/falco"
-	"github.com/falcosecurity/testing/pkg/run"
 	"github.com/falcosecurity/testing/tests"
 	"github.com/falcosecurity/testing/tests/data/captures"
 	"github.com/falcosecurity/testing/tests/data/configs"
@@ -814,25 +807,24 @@ func TestFalco_Legacy_InvalidRuleOutput(t *testing.T) {
 
 func TestFalco_Legacy_FileOutputStrict(t *testing.T) {
 	t.Parallel()
-	run.WorkDir(func(workDir string) {
-		outFilePath := workDir + "/file_output.txt"
-		res := falco.Test(
-			tests.NewFalcoExecutableRunner(t),
-			falco.WithConfig(configs.FileOutput),
-			falco.WithRules(rules.SingleRule),
-			falco.WithCaptureFile(captures.CatWrite),
-			falco.WithArgs("-o", "time_format_iso_8601=true"),
-			falco.WithArgs("-o", "file_output.filename="+outFilePath),
-		)
-
-		outFile := run.NewLocalFileAccessor(outFilePath, outFilePath)
-		actualContent, err1 := outFile.Content()
-		expectedContent, err2 := outputs.SingleRuleWithCatWriteText.Content()
-		assert.Nil(t, err1)
-		assert.Nil(t, err2)
-		assert.Equal(t, string(expectedContent), string(actualContent))
-		assert.Equal(t, 0, res.ExitCode())
-	})
+	runner := tests.NewFalcoExecutableRunner(t)
+	outFilePath := runner.WorkDir() + "/file_output.txt"
+	res := falco.Test(
+		runner,
+		falco.WithConfig(configs.FileOutput),
+		falco.WithRules(rules.SingleRule),
+		falco.WithCaptureFile(captures.CatWrite),
+		falco.WithArgs("-o", "time_format_iso_8601=true"),
+		falco.WithArgs("-o", "file_output.filename="+outFilePath),
+		falco.WithOutputFiles(outFilePath),
+	)
+
+	actualContent, err1 := res.File(outFilePath)
+	expectedContent, err2 := outputs.SingleRuleWithCatWriteText.Content()
+	assert.Nil(t, err1)
+	assert.Nil(t, err2)
+	assert.Equal(t, string(expectedContent), string(actualContent))
+	assert.Equal(t, 0, res.ExitCode())
 }
 
 func TestFalco_Legacy_RunTagsBc(t *testing.T) {
@@ -2914,82 +2906,38 @@ func TestFalco_Legacy_TestWarnings(t *testing.T) {
 	assert.NotNil(t, warnings.OfItemName("not_with_evttypes_addl"))
 }
 
-func grpcOutputResponseToFalcoAlert(res *grpcOutputs.Response) *falco.Alert {
-	outputFields := make(map[string]interface{})
-	for k, v := range res.OutputFields {
-		outputFields[k] = v
-	}
-	return &falco.Alert{
-		Time:         res.Time.AsTime(),
-		Rule:         res.Rule,
-		Output:       res.Output,
-		Priority:     res.Priority.String(),
-		Source:       res.Source,
-		Hostname:     res.Hostname,
-		Tags:         res.Tags,
-		OutputFields: outputFields,
-	}
-}
-
 func TestFalco_Legacy_GrpcUnixSocketOutputs(t *testing.T) {
-	var wg sync.WaitGroup
-	defer wg.Wait()
 	t.Parallel()
 
 	// launch falco asynchronously
 	ctx, ctxCancel := context.WithCancel(context.Background())
-	defer ctxCancel()
-	runner := tests.NewFalcoExecutableRunner(t)
-	socketName := runner.WorkDir() + "/falco.sock"
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		res := falco.Test(
-			runner,
-			falco.WithContext(ctx),
-			falco.WithRules(rules.SingleRuleWithTags),
-			falco.WithConfig(configs.GrpcUnixSocket),
-			falco.WithCaptureFile(captures.CatWrite),
-			falco.WithStopAfter(30*time.Second),
-			falco.WithArgs("-o", "time_format_iso_8601=true"),
-			falco.WithArgs("-o", "grpc.bind_address=unix://"+socketName),
-		)
+	falcoProc := falco.Start(
+		tests.NewFalcoExecutableRunner(t),
+		falco.WithContext(ctx),
+		falco.WithRules(rules.SingleRuleWithTags),
+		falco.WithConfig(configs.GrpcUnixSocket),
+		falco.WithCaptureFile(captures.CatWrite),
+		falco.WithStopAfter(30*time.Second),
+		falco.WithArgs("-o", "time_format_iso_8601=true"),
+		falco.WithGRPCOutput(falco.GRPCUnix),
+	)
+	defer func() {
+		ctxCancel()
+		res := falcoProc.Wait()
 		require.NotContains(t, res.Stderr(), "Error starting gRPC server")
 		// todo(jasondellaluce): skipping this as it can be flacky (Falco sometimes shutsdown
 		// with exit code -1), we need to investigate on that
 		// require.Nil(t, res.Err())
 	}()
+	require.NoError(t, falcoProc.Err())
 
-	// wait up until Falco creates the unix socket
-	for i := 0; i < 10; i++ {
-		if _, err := os.Stat(socketName); err != nil {
-			time.Sleep(100 * time.Millisecond)
-			continue
-		}
-		break
-	}
-
-	// connect using the Falco grpc client and collect detection
-	grpcClient, err := client.NewForConfig(ctx, &client.Config{UnixSocketPath: "unix://" + socketName})
-	require.Nil(t, err)
-
+	// collect the detections with the Falco grpc client
 	expectedCount := 8
-	expectedErr := errors.New("expected error")
-	detections := make(falco.Detections, 0)
-	err = grpcClient.OutputsWatch(context.Background(), func(res *grpcOutputs.Response) error {
-		detections = append(detections, grpcOutputResponseToFalcoAlert(res))
-		if len(detections) == expectedCount {
-			// note: we stop Falco when we reache the number of expected
-			// detections
-			ctxCancel()
-			return expectedErr
-		}
-		return nil
-	}, 100*time.Millisecond)
+	detections, err := falcoProc.GRPC().Detections(ctx, expectedCount)
 
 	// perform checks on the detections
 	// todo(jasondellaluce): add deeper checks on the received struct
-	require.Equal(t, expectedErr, err)
+	require.NoError(t, err)
 	assert.Equal(t, expectedCount, detections.Count())
 	assert.Equal(t, expectedCount, detections.
 		OfPriority("WARNING").
@@ -19,12 +19,13 @@ limitations under the License.
 package testfalco
 
 import (
+	"context"
 	"github.com/falcosecurity/testing/pkg/run"
 	"github.com/falcosecurity/testing/tests/data/rules"
-	"net/http"
 	"os"
 	"path/filepath"
-	"sync"
+	"regexp"
+	"syscall"
 	"testing"
 	"time"
 
@@ -33,21 +34,19 @@ import (
 	"github.com/falcosecurity/testing/tests/data/configs"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // todo(jasondellaluce): implement tests for the non-covered Falco config fields:
 //   watch_config_files, libs_logger, buffered_outputs, syscall_event_timeouts,
-//   syslog_output, file_output, stdout_output, webserver, program_output,
-//   http_output, metadata_download, output_timeout, outputs
+//   file_output, stdout_output,
+//   metadata_download, output_timeout, outputs
 //
 // todo(jasondellaluce): test Falco behavior on environment variables and their
 // priorities in combination with their args/configs/cmds counterparts:
 //   FALCO_K8S_API, FALCO_K8S_API_CERT, FALCO_MESOS_API, FALCO_HOSTNAME,
 //   FALCO_GRPC_HOSTNAME, FALCO_BPF_PROBE, HOME (used for bpf probe)
 //
-// todo(jasondellaluce): implement tests for Falco reaction to signals:
-//   SIGINT, SIGUSR1, SIGHUP
-//
 // todo(jasondellaluce): implement tests for other non-covered Falco things:
 //   - collection of live events with kmod, bpf, modern-bpf, gvisor, userspace
 //   - collection of live events with multiple event sources active at the same
@@ -89,20 +88,21 @@ func TestFalco_Miscs_HotReload(t *testing.T) {
 		_ = os.Remove(path)
 	})
 
-	go func() {
-		time.Sleep(2 * time.Second)
-		f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0700)
-		_, _ = f.WriteString("  \n\n")
-		_ = f.Close()
-	}()
-
-	falcoRes := falco.Test(
+	falcoProc := falco.Start(
 		tests.NewFalcoExecutableRunner(t),
 		falco.WithConfig(hotReloadCfg),
 		falco.WithRules(rules.SingleRule),
 		falco.WithStopAfter(5*time.Second),
 		falco.WithArgs("-o", "engine.kind=nodriver"),
+		falco.WithReadyWhen(falco.LogLineMatches(regexp.MustCompile(`Opening .* source with`))),
 	)
+	assert.NoError(t, falcoProc.Err())
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0700)
+	_, _ = f.WriteString("  \n\n")
+	_ = f.Close()
+
+	falcoRes := falcoProc.Wait()
 	assert.NoError(t, falcoRes.Err(), "%s", falcoRes.Stderr())
 	assert.Equal(t, 0, falcoRes.ExitCode())
 	// We want to be sure that the hot reload was triggered
@@ -110,29 +110,110 @@ func TestFalco_Miscs_HotReload(t *testing.T) {
 }
 
 func TestFalco_Miscs_PrometheusMetricsNoDriver(t *testing.T) {
-	var (
-		wg         sync.WaitGroup
-		metricsErr error
-	)
-	wg.Add(1)
-
-	go func() {
-		defer wg.Done()
-		time.Sleep(2 * time.Second)
-		_, metricsErr = http.Get("http://127.0.0.1:8765/metrics")
-	}()
-
-	falcoRes := falco.Test(
+	t.Parallel()
+	falcoProc := falco.Start(
 		tests.NewFalcoExecutableRunner(t),
 		falco.WithPrometheusMetrics(),
+		falco.WithOutputJSON(),
 		falco.WithRules(rules.SingleRule),
 		falco.WithStopAfter(5*time.Second),
 		falco.WithArgs("-o", "engine.kind=nodriver"),
+		falco.WithReadyWhen(falco.WebserverEndpointUp(falco.MetricsPath)),
 	)
+	assert.NoError(t, falcoProc.Err())
+
+	falcoRes := falcoProc.Wait()
 	assert.NoError(t, falcoRes.Err(), "%s", falcoRes.Stderr())
 	assert.Equal(t, 0, falcoRes.ExitCode())
+	require.NotEmpty(t, falcoRes.Metrics())
+	assert.Empty(t, falcoRes.Metrics().Last().Missing(
+		"falcosecurity_falco_version_info",
+		"falcosecurity_falco_outputs_queue_num_drops_total",
+	))
+	assert.NotEmpty(t, falcoRes.Metrics().Last().Names("falcosecurity_"))
+	require.NotEmpty(t, falcoRes.MetricsSnapshots())
+	assert.NotZero(t, falcoRes.MetricsSnapshots().Last().MemoryRSS)
+}
 
-	wg.Wait()
+func TestFalco_Miscs_Webserver(t *testing.T) {
+	t.Parallel()
+	checkConfig(t)
+	runner := tests.NewFalcoExecutableRunner(t)
+
+	// the versions reported by the webserver must match the ones of the CLI
+	res := falco.Test(runner, falco.WithArgs("--version"), falco.WithOutputJSON())
+	require.NoError(t, res.Err(), "%s", res.Stderr())
+	expected := res.Version()
+	require.NotNil(t, expected)
+
+	for name, opt := range map[string]falco.TestOption{
+		"http":  falco.WithWebserver(),
+		"https": falco.WithWebserverTLS(),
+	} {
+		name, opt := name, opt
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			falcoProc := falco.Start(
+				tests.NewFalcoExecutableRunner(t),
+				opt,
+				falco.WithRules(rules.SingleRule),
+				falco.WithStopAfter(5*time.Second),
+				falco.WithArgs("-o", "engine.kind=nodriver"),
+				falco.WithReadyWhen(falco.WebserverEndpointUp(falco.HealthzPath)),
+			)
+			require.NoError(t, falcoProc.Err())
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			assert.NoError(t, falcoProc.Webserver().Healthz(ctx))
+			versions, err := falcoProc.Webserver().Versions(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, versions)
+
+			falcoRes := falcoProc.Wait()
+			assert.NoError(t, falcoRes.Err(), "%s", falcoRes.Stderr())
+			assert.Equal(t, 0, falcoRes.ExitCode())
+			assert.Contains(t, falcoRes.WebserverURL(), name+"://127.0.0.1:")
+		})
+	}
+}
 
-	assert.NoError(t, metricsErr)
+func TestFalco_Miscs_Signals(t *testing.T) {
+	runner := tests.NewFalcoExecutableRunner(t)
+	t.Run("sighup", func(t *testing.T) {
+		res := falco.Test(
+			runner,
+			falco.WithRules(rules.SingleRule),
+			falco.WithStopAfter(5*time.Second),
+			falco.WithArgs("-o", "engine.kind=nodriver"),
+			falco.WithSignalAt(2*time.Second, syscall.SIGHUP),
+		)
+		assert.NoError(t, res.Err(), "%s", res.Stderr())
+		assert.Equal(t, 0, res.ExitCode())
+		assert.Equal(t, 1, res.Restarts())
+	})
+	t.Run("sigusr1", func(t *testing.T) {
+		res := falco.Test(
+			runner,
+			falco.WithRules(rules.SingleRule),
+			falco.WithStopAfter(5*time.Second),
+			falco.WithArgs("-o", "engine.kind=nodriver"),
+			falco.WithSignalAt(2*time.Second, syscall.SIGUSR1),
+		)
+		assert.NoError(t, res.Err(), "%s", res.Stderr())
+		assert.Equal(t, 0, res.ExitCode())
+		assert.Equal(t, 1, res.ReopenedOutputs())
+		assert.Equal(t, 0, res.Restarts())
+	})
+	t.Run("sigint", func(t *testing.T) {
+		res := falco.Test(
+			runner,
+			falco.WithRules(rules.SingleRule),
+			falco.WithStopAfter(30*time.Second),
+			falco.WithArgs("-o", "engine.kind=nodriver"),
+			falco.WithSignalAt(2*time.Second, syscall.SIGINT),
+		)
+		assert.NoError(t, res.Err(), "%s", res.Stderr())
+		assert.False(t, res.DurationExceeded())
+		assert.True(t, res.GracefulShutdown())
+	})
 }
@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package testfalco
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/falcosecurity/testing/pkg/falco"
+	"github.com/falcosecurity/testing/pkg/golden"
+	"github.com/falcosecurity/testing/pkg/run"
+	"github.com/falcosecurity/testing/pkg/sinks"
+	"github.com/falcosecurity/testing/tests"
+	"github.com/falcosecurity/testing/tests/data/captures"
+	"github.com/falcosecurity/testing/tests/data/configs"
+	"github.com/falcosecurity/testing/tests/data/rules"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFalco_Outputs_TextDetections(t *testing.T) {
+	t.Parallel()
+	runner := tests.NewFalcoExecutableRunner(t)
+	t.Run("time-iso8601", func(t *testing.T) {
+		t.Parallel()
+		res := falco.Test(
+			runner,
+			falco.WithConfig(configs.StdoutOutput),
+			falco.WithRules(rules.SingleRule),
+			falco.WithCaptureFile(captures.CatWrite),
+			falco.WithArgs("-o", "time_format_iso_8601=true"),
+		)
+		assert.NoError(t, res.Err(), "%s", res.Stderr())
+		assert.Equal(t, 0, res.ExitCode())
+		assert.Equal(t, 8, res.Detections().Count())
+		assert.Equal(t, 8, res.Detections().OfPriority("WARNING").Count())
+		assert.Equal(t, 2016, res.Detections().First().Time.Year())
+	})
+	t.Run("time-default", func(t *testing.T) {
+		t.Parallel()
+		res := falco.Test(
+			runner,
+			falco.WithConfig(configs.StdoutOutput),
+			falco.WithRules(rules.SingleRule),
+			falco.WithCaptureFile(captures.CatWrite),
+			falco.WithArgs("-o", "time_format_iso_8601=false"),
+		)
+		assert.NoError(t, res.Err(), "%s", res.Stderr())
+		assert.Equal(t, 0, res.ExitCode())
+		assert.Equal(t, 8, res.Detections().Count())
+		assert.Equal(t, 8, res.Detections().OfPriority("WARNING").Count())
+	})
+	t.Run("custom-format", func(t *testing.T) {
+		t.Parallel()
+		res := falco.Test(
+			runner,
+			falco.WithConfig(configs.StdoutOutput),
+			falco.WithRules(rules.SingleRule),
+			falco.WithCaptureFile(captures.CatWrite),
+			falco.WithArgs("-o", "time_format_iso_8601=true"),
+			falco.WithArgs("-p", "rule=%rule"),
+			falco.WithTextOutputFormat(regexp.MustCompile(
+				`^(?P<time>\S+): (?P<priority>\w+) .* rule=(?P<rule>\S+)$`)),
+		)
+		assert.NoError(t, res.Err(), "%s", res.Stderr())
+		assert.Equal(t, 0, res.ExitCode())
+		assert.Equal(t, 8, res.Detections().OfRule("open_from_cat").Count())
+	})
+}
+
+func TestFalco_Outputs_GoldenDetections(t *testing.T) {
+	t.Parallel()
+	res := falco.Test(
+		tests.NewFalcoExecutableRunner(t),
+		falco.WithConfig(configs.StdoutOutput),
+		falco.WithOutputJSON(),
+		falco.WithRules(rules.SingleRuleWithTags),
+		falco.WithCaptureFile(captures.CatWrite),
+		falco.WithArgs("-o", "time_format_iso_8601=true"),
+		falco.WithArgs("-o", "json_include_output_property=true"),
+		falco.WithArgs("-o", "json_include_tags_property=true"),
+	)
+	assert.NoError(t, res.Err(), "%s", res.Stderr())
+	assert.Equal(t, 0, res.ExitCode())
+	golden.AssertDetections(t, "single_rule_with_cat_write.json", res.Detections())
+}
+
+func TestFalco_Outputs_HTTP(t *testing.T) {
+	t.Parallel()
+	runner := tests.NewFalcoExecutableRunner(t)
+	testCases := []struct {
+		name    string
+		options *sinks.HTTPSinkOptions
+		args    []string
+	}{
+		{name: "plain"},
+		{name: "tls", options: &sinks.HTTPSinkOptions{TLS: true}},
+		{name: "mtls", options: &sinks.HTTPSinkOptions{MTLS: true}},
+		{name: "keep-alive", args: []string{"-o", "http_output.keep_alive=true"}},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			sink, err := sinks.NewHTTPSink(tc.options)
+			require.NoError(t, err)
+			defer sink.Close()
+			res := falco.Test(
+				runner,
+				falco.WithConfig(configs.StdoutOutput),
+				falco.WithOutputJSON(),
+				falco.WithRules(rules.SingleRule),
+				falco.WithCaptureFile(captures.CatWrite),
+				falco.WithHTTPOutput(sink),
+				falco.WithArgs(tc.args...),
+			)
+			assert.NoError(t, res.Err(), "%s", res.Stderr())
+			assert.Equal(t, 0, res.ExitCode())
+			assert.Equal(t, 8, sink.Detections().OfRule("open_from_cat").Count())
+			for _, r := range sink.Requests() {
+				assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+				assert.Equal(t, tc.options != nil, r.TLS)
+			}
+			if tc.name == "keep-alive" {
+				assert.Equal(t, 1, sink.Connections())
+			}
+		})
+	}
+}
+
+func TestFalco_Outputs_Syslog(t *testing.T) {
+	t.Parallel()
+	sink, err := sinks.NewSyslogSink(nil)
+	require.NoError(t, err)
+	defer sink.Close()
+	res := falco.Test(
+		tests.NewFalcoDockerRunner(t, &run.DockerRunnerOptions{Binds: []string{sink.DockerBind()}}),
+		falco.WithOutputJSON(),
+		falco.WithRules(rules.SingleRule),
+		falco.WithCaptureFile(captures.CatWrite),
+		falco.WithSyslogOutput(),
+	)
+	assert.NoError(t, res.Err(), "%s", res.Stderr())
+	assert.Equal(t, 0, res.ExitCode())
+	// note: syslog messages are received asynchronously
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, sink.Wait(ctx, 8))
+	assert.Equal(t, 8, sink.Detections().OfRule("open_from_cat").Count())
+	for _, m := range sink.Messages() {
+		// falco priorities must map to the matching syslog severities
+		assert.Equal(t, "Warning", m.Priority())
+		assert.Equal(t, m.Alert().Priority, m.Priority())
+	}
+}
+
+func TestFalco_Outputs_Program(t *testing.T) {
+	t.Parallel()
+	runner := tests.NewFalcoExecutableRunner(t)
+	for _, keepAlive := range []bool{false, true} {
+		keepAlive := keepAlive
+		t.Run(fmt.Sprintf("keep-alive-%t", keepAlive), func(t *testing.T) {
+			t.Parallel()
+			res := falco.Test(
+				runner,
+				falco.WithConfig(configs.StdoutOutput),
+				falco.WithOutputJSON(),
+				falco.WithRules(rules.SingleRule),
+				falco.WithCaptureFile(captures.CatWrite),
+				falco.WithProgramOutput(keepAlive),
+			)
+			assert.NoError(t, res.Err(), "%s", res.Stderr())
+			assert.Equal(t, 0, res.ExitCode())
+			require.NotNil(t, res.ProgramOutput())
+			assert.Equal(t, 8, res.ProgramOutput().Detections().OfRule("open_from_cat").Count())
+			if keepAlive {
+				assert.Equal(t, 1, res.ProgramOutput().Spawns)
+			} else {
+				assert.Equal(t, 8, res.ProgramOutput().Spawns)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+/*
+Copyright (C) 2023 The Falco Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+*/
+
+package testfalco
+
+import (
+	"testing"
+
+	"github.com/falcosecurity/testing/pkg/falco"
+	falcorules "github.com/falcosecurity/testing/pkg/rules"
+	"github.com/falcosecurity/testing/tests"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFalco_Rules_Overrides(t *testing.T) {
+	t.Parallel()
+	checkConfig(t)
+	newRulesFile := func() *falcorules.File {
+		return falcorules.NewFile(
+			&falcorules.List{Name: "cat_binaries", Items: []string{"cat"}},
+			&falcorules.Macro{Name: "is_cat", Condition: "proc.name in (cat_binaries)"},
+			&falcorules.Rule{
+				Name:      "open_from_cat",
+				Desc:      "A process named cat does an open",
+				Condition: "evt.type=open and is_cat",
+				Output:    "An open was seen (command=%proc.cmdline)",
+				Priority:  "WARNING",
+			},
+		)
+	}
+	testCases := map[string]struct {
+		item  falcorules.Item
+		check func(t *testing.T, d *falco.RulesetDescription)
+	}{
+		"list-append": {
+			item: falcorules.AppendList("cat_binaries", "bat"),
+			check: func(t *testing.T, d *falco.RulesetDescription) {
+				require.Len(t, d.Lists, 1)
+				assert.Equal(t, []string{"cat", "bat"}, d.Lists[0].Info.Items)
+			},
+		},
+		"macro-append": {
+			item: falcorules.AppendMacro("is_cat", "or proc.name=bat"),
+			check: func(t *testing.T, d *falco.RulesetDescription) {
+				require.Len(t, d.Macros, 1)
+				assert.Equal(t, "proc.name in (cat_binaries) or proc.name=bat", d.Macros[0].Info.Condition)
+			},
+		},
+		"rule-replace": {
+			item: falcorules.Rule{Name: "open_from_cat", Priority: "ERROR"}.
+				Overriding(falcorules.OverrideReplace, "priority"),
+			check: func(t *testing.T, d *falco.RulesetDescription) {
+				require.Len(t, d.Rules, 1)
+				assert.Equal(t, "Error", d.Rules[0].Info.Priority)
+			},
+		},
+	}
+	runner := tests.NewFalcoExecutableRunner(t)
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rulesFile, err := newRulesFile().Add(tc.item).File("rules.yaml")
+			require.NoError(t, err)
+			res := falco.Test(
+				runner,
+				falco.WithArgs("-L"),
+				falco.WithOutputJSON(),
+				falco.WithRules(rulesFile),
+			)
+			assert.NoError(t, res.Err(), "%s", res.Stderr())
+			assert.Equal(t, 0, res.ExitCode())
+			desc := res.RulesetDescription()
+			require.NotNil(t, desc)
+			tc.check(t, desc)
+		})
+	}
+}
@@ -50,12 +50,11 @@ func TestFalcoLegacyBPF(t *testing.T) {
 	// We expect the probe to be succesfully built and copied to /root/.falco/falco-bpf.o
 	assert.Regexp(t, `eBPF probe available.`, loaderRes.Stdout())
 
-	// Now running Falco with `FALCO_BPF_PROBE=/root/.falco/falco-bpf.o` we should be able to run the bpf driver
+	// Now running Falco with the probe at /root/.falco/falco-bpf.o we should be able to run the bpf driver
 	falcoRes := falco.Test(
 		tests.NewFalcoExecutableRunner(t),
 		falco.WithStopAfter(3*time.Second),
-		falco.WithArgs("-o", "engine.kind=ebpf"),
-		falco.WithArgs("-o", "engine.ebpf.probe=/root/.falco/falco-bpf.o"),
+		falco.WithEngine(falco.EngineEbpf, &falco.EngineOptions{Probe: "/root/.falco/falco-bpf.o"}),
 	)
 	assert.NoError(t, falcoRes.Err(), "%s", falcoRes.Stderr())
 	assert.Equal(t, 0, falcoRes.ExitCode())
@@ -90,7 +89,7 @@ func TestFalcoKmod(t *testing.T) {
 	falcoRes := falco.Test(
 		tests.NewFalcoExecutableRunner(t),
 		falco.WithStopAfter(3*time.Second),
-		falco.WithArgs("-o", "engine.kind=kmod"),
+		falco.WithEngine(falco.EngineKmod, nil),
 	)
 	assert.NoError(t, falcoRes.Err(), "%s", falcoRes.Stderr())
 	assert.Equal(t, 0, falcoRes.ExitCode())
@@ -100,19 +99,27 @@ func TestFalcoKmod(t *testing.T) {
 	assert.Regexp(t, `Events detected:`, falcoRes.Stdout())
 }
 
-// This test doesn't need the falco-driver-loader but we put it here
-// together with the other engines.
-func TestFalcoModernBpf(t *testing.T) {
-	// Now running Falco we should be able to run the kernel module
-	falcoRes := falco.Test(
-		tests.NewFalcoExecutableRunner(t),
-		falco.WithStopAfter(3*time.Second),
-		falco.WithArgs("-o", "engine.kind=modern_ebpf"),
-	)
-	assert.NoError(t, falcoRes.Err(), "%s", falcoRes.Stderr())
-	assert.Equal(t, 0, falcoRes.ExitCode())
-	// We want to be sure to run the Kernel module.
-	assert.Regexp(t, `source with modern BPF probe`, falcoRes.Stderr())
-	// We want to be sure that the engine is correctly opened.
-	assert.Regexp(t, `Events detected:`, falcoRes.Stdout())
+// TestFalco_Engines runs Falco with each of the kernel drivers that
+// don't need the driver loader, which are tested above otherwise.
+// Engines that are not available are skipped.
+func TestFalco_Engines(t *testing.T) {
+	engines := []tests.Engine{
+		{Kind: falco.EngineModernEbpf},
+	}
+	sources := map[falco.EngineKind]string{
+		falco.EngineModernEbpf: `source with modern BPF probe`,
+	}
+	tests.RunEngineMatrix(t, engines, func(t *testing.T, e tests.Engine) {
+		res := falco.Test(
+			tests.NewFalcoExecutableRunner(t),
+			falco.WithStopAfter(3*time.Second),
+			e.Option(),
+		)
+		assert.NoError(t, res.Err(), "%s", res.Stderr())
+		assert.Equal(t, 0, res.ExitCode())
+		// We want to be sure to run the right driver.
+		assert.Regexp(t, sources[e.Kind], res.Stderr())
+		// We want to be sure that the engine is correctly opened.
+		assert.Regexp(t, `Events detected:`, res.Stdout())
+	})
 }
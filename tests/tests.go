@@ -34,12 +34,16 @@ import (
 var (
 	falcoStatic    = false
 	falcoBinary    = falco.DefaultExecutable
+	falcoImage     = "docker.io/falcosecurity/falco-no-driver:latest"
+	falcoImageBin  = falco.DefaultExecutable
 	falcoctlBinary = falcoctl.DefaultLocalExecutable
 )
 
 func init() {
 	flag.BoolVar(&falcoStatic, "falco-static", falcoStatic, "True if the Falco executable is from a static build")
 	flag.StringVar(&falcoBinary, "falco-binary", falcoBinary, "Falco executable binary path")
+	flag.StringVar(&falcoImage, "falco-image", falcoImage, "Falco container image used by tests running Falco with Docker")
+	flag.StringVar(&falcoImageBin, "falco-image-binary", falcoImageBin, "Falco executable binary path inside the container image")
 	flag.StringVar(&falcoctlBinary, "falcoctl-binary", falcoctlBinary, "falcoctl executable binary path")
 	flag.StringVar(&falco.FalcoConfig, "falco-config", falco.FalcoConfig, "Falco config file path")
 
@@ -54,6 +58,16 @@ func NewFalcoExecutableRunner(t *testing.T) run.Runner {
 	return runner
 }
 
+// NewFalcoDockerRunner returns a Docker runner for Falco, or skips the
+// test if Docker is not available.
+func NewFalcoDockerRunner(t *testing.T, options *run.DockerRunnerOptions) run.Runner {
+	runner, err := run.NewDockerRunner(falcoImage, falcoImageBin, options)
+	if err != nil {
+		t.Skipf("can't run falco with docker: %s", err.Error())
+	}
+	return runner
+}
+
 // NewFalcoctlExecutableRunner returns an executable runner for falcoctl.
 func NewFalcoctlExecutableRunner(t *testing.T) run.Runner {
 	if _, err := os.Stat(falcoctlBinary); err == nil {